@@ -10,15 +10,23 @@ import (
 
 	"L0/internal/cache"
 	"L0/internal/config"
+	"L0/internal/health"
 	"L0/internal/kafka"
 	"L0/internal/logger"
 	"L0/internal/repository"
 	"L0/internal/server"
 	"L0/internal/service"
+	"L0/internal/tracing"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+// healthCheckTimeout limits each dependency probe of /readyz
+const healthCheckTimeout = 2 * time.Second
+
+// tracingShutdownTimeout limits flushing of pending spans on shutdown
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	log := logger.NewLogger().WithField("component", "main")
 	log.Info("Starting L0 service")
@@ -26,6 +34,19 @@ func main() {
 	cfg := config.NewConfig()
 	log.Info("Configuration loaded")
 
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
+	log.Infof("Tracing initialized: exporter=%s", cfg.Tracing.Exporter)
+
 	repo, err := repository.NewPostgresRepository(cfg)
 	if err != nil {
 		log.Errorf("Failed to connect to database: %v", err)
@@ -33,10 +54,16 @@ func main() {
 	}
 	log.Info("Database connection established")
 
-	cache := cache.NewRedisCache(cfg.Redis, log)
-	log.Info("Redis cache initialized")
+	cache, err := cache.NewCache(cfg, log)
+	if err != nil {
+		log.Fatalf("failed to create cache: %v", err)
+	}
+	log.Infof("Cache initialized: backend=%s", cfg.Cache.Backend)
 
-	orderService := service.NewOrderService(repo, cache, log)
+	orderService, err := service.NewOrderService(cfg, repo, cache, log)
+	if err != nil {
+		log.Fatalf("failed to create order service: %v", err)
+	}
 	log.Info("Order service initialized")
 
 	migrationsPath, err := filepath.Abs("migrations")
@@ -59,6 +86,10 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if cfg.Cache.Warmup {
+		go orderService.WarmUpCache(ctx)
+	}
+
 	go func() {
 		log.Info("Starting Kafka consumer")
 		if err := consumer.Start(ctx); err != nil {
@@ -66,7 +97,28 @@ func main() {
 		}
 	}()
 
-	handler := server.NewHandler(orderService, log)
+	if cfg.Outbox.Topic != "" {
+		publisher := kafka.NewPublisher(cfg)
+		defer publisher.Close()
+
+		relay := service.NewOutboxRelay(cfg, repo, publisher, log)
+		go func() {
+			log.Infof("Starting outbox relay to topic %s", cfg.Outbox.Topic)
+			relay.Run(ctx)
+		}()
+	}
+
+	checks := []health.Check{
+		{Name: "postgres", Ping: repo.Ping},
+		{Name: "kafka", Ping: consumer.Ping},
+	}
+	// The in-process cache has no dependency worth probing
+	if cfg.Cache.Backend != "memory" {
+		checks = append(checks, health.Check{Name: "redis", Ping: cache.Ping})
+	}
+	checker := health.NewChecker(healthCheckTimeout, checks...)
+
+	handler := server.NewHandler(orderService, checker, log)
 	appServer := server.NewServer(handler)
 	go func() {
 		log.Info("Starting HTTP server on :8081")
@@ -86,5 +138,9 @@ func main() {
 
 	// timeout for graceful shutdown
 	time.Sleep(3 * time.Second)
+
+	if err := cache.Close(); err != nil {
+		log.Errorf("Error closing cache: %v", err)
+	}
 	log.Info("Service shutdown completed")
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileReader yields the JSON values of the files one by one. A file may hold
+// a single (pretty-printed) value, a JSON array of values or a stream of
+// values such as NDJSON; "-" is the standard input.
+type fileReader struct {
+	paths []string
+	file  *os.File
+	dec   *json.Decoder
+	array bool // reading elements of a top-level array
+	path  string
+}
+
+func newFileReader(paths []string) *fileReader {
+	return &fileReader{paths: paths}
+}
+
+// next returns the next value compacted to a single line, or io.EOF after
+// the last file
+func (f *fileReader) next() ([]byte, error) {
+	for {
+		if f.dec == nil {
+			if len(f.paths) == 0 {
+				return nil, io.EOF
+			}
+			if err := f.open(f.paths[0]); err != nil {
+				return nil, err
+			}
+			f.paths = f.paths[1:]
+		}
+
+		if f.dec.More() {
+			var raw json.RawMessage
+			if err := f.dec.Decode(&raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.path, err)
+			}
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.path, err)
+			}
+			return buf.Bytes(), nil
+		}
+
+		if f.array {
+			if _, err := f.dec.Token(); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.path, err)
+			}
+		}
+		if err := f.close(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *fileReader) open(path string) error {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	f.array = startsWith(reader, '[')
+	f.dec = json.NewDecoder(reader)
+	f.file = file
+	f.path = path
+
+	if f.array {
+		// Skip the opening bracket, values are decoded one by one
+		if _, err := f.dec.Token(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (f *fileReader) close() error {
+	var err error
+	if f.file != os.Stdin {
+		err = f.file.Close()
+	}
+	f.file, f.dec, f.array = nil, nil, false
+	return err
+}
+
+// startsWith reports whether the first non-space byte is b
+func startsWith(r *bufio.Reader, b byte) bool {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		r.UnreadByte()
+		return c == b
+	}
+}
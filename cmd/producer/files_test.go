@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readAll returns every value of the files up to io.EOF
+func readAll(t *testing.T, paths ...string) ([]string, error) {
+	t.Helper()
+	f := newFileReader(paths)
+	var values []string
+	for {
+		data, err := f.next()
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, string(data))
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "single value",
+			content: "{\n  \"order_uid\": \"a\",\n  \"items\": [\n    {\"price\": 1}\n  ]\n}\n",
+			want:    []string{`{"order_uid":"a","items":[{"price":1}]}`},
+		},
+		{
+			name:    "array",
+			content: "[\n  {\"order_uid\": \"a\"},\n  {\"order_uid\": \"b\"}\n]\n",
+			want:    []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:    "array after blank lines",
+			content: "\n\t \r\n[{\"order_uid\": \"a\"}]",
+			want:    []string{`{"order_uid":"a"}`},
+		},
+		{
+			name:    "empty array",
+			content: "[]",
+			want:    nil,
+		},
+		{
+			name:    "ndjson",
+			content: "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n\n{\"order_uid\":\"c\"}\n",
+			want:    []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`, `{"order_uid":"c"}`},
+		},
+		{
+			name:    "concatenated values",
+			content: `{"order_uid":"a"} {"order_uid":"b"}`,
+			want:    []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, writeFile(t, "orders.json", tt.content))
+			if err != nil {
+				t.Fatalf("next(): %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileReaderFilesInOrder(t *testing.T) {
+	got, err := readAll(t,
+		writeFile(t, "a.json", `{"order_uid":"a"}`),
+		writeFile(t, "b.json", `[{"order_uid":"b"},{"order_uid":"c"}]`),
+		writeFile(t, "empty.json", ``),
+		writeFile(t, "d.ndjson", "{\"order_uid\":\"d\"}\n{\"order_uid\":\"e\"}\n"),
+	)
+	if err != nil {
+		t.Fatalf("next(): %v", err)
+	}
+	want := []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`, `{"order_uid":"c"}`, `{"order_uid":"d"}`, `{"order_uid":"e"}`}
+	if !slices.Equal(got, want) {
+		t.Errorf("values = %q, want %q", got, want)
+	}
+}
+
+func TestFileReaderStdin(t *testing.T) {
+	stdin, err := os.Open(writeFile(t, "stdin.ndjson", "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	saved := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = saved }()
+
+	got, err := readAll(t, writeFile(t, "first.json", `{"order_uid":"first"}`), "-")
+	if err != nil {
+		t.Fatalf("next(): %v", err)
+	}
+	want := []string{`{"order_uid":"first"}`, `{"order_uid":"a"}`, `{"order_uid":"b"}`}
+	if !slices.Equal(got, want) {
+		t.Errorf("values = %q, want %q", got, want)
+	}
+}
+
+func TestFileReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string // values read before the error
+	}{
+		{
+			name: "missing file",
+			path: filepath.Join(t.TempDir(), "missing.json"),
+		},
+		{
+			name: "malformed value",
+			path: writeFile(t, "bad.ndjson", "{\"order_uid\":\"a\"}\n{\"order_uid\":\n"),
+			want: []string{`{"order_uid":"a"}`},
+		},
+		{
+			name: "unterminated array",
+			path: writeFile(t, "bad.json", `[{"order_uid":"a"}`),
+			want: []string{`{"order_uid":"a"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.path)
+			if err == nil {
+				t.Fatalf("next() read %q without an error", got)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("values before the error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
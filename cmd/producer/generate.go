@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"L0/internal/models"
+)
+
+// profile is a delivery country whose phone, postcode, currency and locale
+// agree with the reference data the service validates against
+type profile struct {
+	callingCode string
+	phoneDigits int
+	zip         func(r *rand.Rand) string
+	currency    string
+	locale      string
+	cities      []string
+	regions     []string
+}
+
+var profiles = []profile{
+	{
+		callingCode: "7", phoneDigits: 10, zip: digits(6), currency: "RUB", locale: "ru-RU",
+		cities: []string{"Moscow", "Kazan", "Novosibirsk"}, regions: []string{"Moscow", "Tatarstan", "Novosibirsk Oblast"},
+	},
+	{
+		callingCode: "1", phoneDigits: 10, zip: digits(5), currency: "USD", locale: "en-US",
+		cities: []string{"Austin", "Denver", "Portland"}, regions: []string{"Texas", "Colorado", "Oregon"},
+	},
+	{
+		callingCode: "49", phoneDigits: 10, zip: digits(5), currency: "EUR", locale: "de-DE",
+		cities: []string{"Berlin", "Hamburg", "Munich"}, regions: []string{"Berlin", "Hamburg", "Bavaria"},
+	},
+	{
+		callingCode: "972", phoneDigits: 9, zip: digits(7), currency: "ILS", locale: "he-IL",
+		cities: []string{"Kiryat Mozkin", "Haifa", "Tel Aviv"}, regions: []string{"Kraiot", "Haifa", "Tel Aviv"},
+	},
+}
+
+var (
+	firstNames = []string{"John", "Anna", "Ivan", "Maria", "David", "Olga"}
+	lastNames  = []string{"Doe", "Ivanova", "Petrov", "Smith", "Cohen", "Muller"}
+	products   = []struct{ name, brand string }{
+		{"Боксеры", "Беларусский трикотаж"},
+		{"Mascaras", "Vivienne Sabo"},
+		{"Sneakers", "Nike"},
+		{"T-shirt", "Uniqlo"},
+		{"Backpack", "Xiaomi"},
+	}
+	sizes     = []string{"0", "S", "M", "L", "XL", "42"}
+	banks     = []string{"alpha", "sber", "tinkoff"}
+	services  = []string{"meest", "cdek", "boxberry"}
+	providers = []string{"wbpay", "applepay"}
+)
+
+// invalidCase breaks a valid order, encoded as a generic JSON object, so that
+// the service rejects it for a known reason
+type invalidCase struct {
+	name  string
+	apply func(order map[string]any)
+}
+
+var invalidCases = []invalidCase{
+	{"no_items", func(o map[string]any) { o["items"] = []any{} }},
+	{"missing_order_uid", func(o map[string]any) { delete(o, "order_uid") }},
+	{"negative_price", func(o map[string]any) { firstItem(o)["price"] = -1 }},
+	{"missing_sale", func(o map[string]any) { delete(firstItem(o), "sale") }},
+	{"invalid_email", func(o map[string]any) { delivery(o)["email"] = "not-an-email" }},
+	{"local_phone", func(o map[string]any) { delivery(o)["phone"] = "89001234567" }},
+	{"zip_country_mismatch", func(o map[string]any) { delivery(o)["zip"] = "ZIP-0" }},
+	{"unknown_currency", func(o map[string]any) { payment(o)["currency"] = "XXY" }},
+	{"invalid_locale", func(o map[string]any) { o["locale"] = "english" }},
+	{"amount_mismatch", func(o map[string]any) { payment(o)["amount"] = json.Number("1000000") }},
+	{"zero_payment_dt", func(o map[string]any) { payment(o)["payment_dt"] = 0 }},
+}
+
+func firstItem(o map[string]any) map[string]any { return o["items"].([]any)[0].(map[string]any) }
+func delivery(o map[string]any) map[string]any  { return o["delivery"].(map[string]any) }
+func payment(o map[string]any) map[string]any   { return o["payment"].(map[string]any) }
+
+// generator makes random orders that pass validation and the default
+// business rules, and invalid ones with the given probability
+type generator struct {
+	rand    *rand.Rand
+	invalid float64
+}
+
+func newGenerator(seed int64, invalid float64) *generator {
+	return &generator{rand: rand.New(rand.NewSource(seed)), invalid: invalid}
+}
+
+// next returns an encoded order and the name of the case it was generated
+// for: valid or one of invalidCases, malformed_json is not JSON at all
+func (g *generator) next() ([]byte, string, error) {
+	order := g.order()
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, "", err
+	}
+	if g.rand.Float64() >= g.invalid {
+		return data, "valid", nil
+	}
+
+	n := g.rand.Intn(len(invalidCases) + 1)
+	if n == len(invalidCases) {
+		return data[:len(data)/2], "malformed_json", nil
+	}
+
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, "", err
+	}
+	invalidCases[n].apply(doc)
+	data, err = json.Marshal(doc)
+	return data, invalidCases[n].name, err
+}
+
+func (g *generator) order() *models.Order {
+	r := g.rand
+	p := profiles[r.Intn(len(profiles))]
+	uid := g.token(19)
+	track := "WB" + strings.ToUpper(g.token(12))
+	created := time.Now().Add(-time.Duration(r.Int63n(int64(30 * 24 * time.Hour))))
+
+	items := make([]models.Item, 1+r.Intn(4))
+	goodsTotal := models.Money{}
+	for i := range items {
+		product := products[r.Intn(len(products))]
+		price := models.MoneyFromFloat(50 + r.Float64()*5000).Round(p.currency)
+		sale := float64(r.Intn(8) * 10)
+		total := price.Percent(100-sale, p.currency)
+		goodsTotal = goodsTotal.Add(total)
+
+		items[i] = models.Item{
+			ChrtID:      1 + r.Intn(9999999),
+			TrackNumber: track,
+			Price:       price,
+			Rid:         g.token(21),
+			Name:        product.name,
+			Sale:        sale,
+			Size:        sizes[r.Intn(len(sizes))],
+			TotalPrice:  total,
+			NmID:        1 + r.Intn(9999999),
+			Brand:       product.brand,
+			Status:      202,
+		}
+	}
+
+	deliveryCost := models.Money{}
+	if r.Intn(4) > 0 {
+		deliveryCost = models.MoneyFromFloat(float64(100 + r.Intn(1500))).Round(p.currency)
+	}
+	customFee := models.Money{}
+	if r.Intn(5) == 0 {
+		customFee = models.MoneyFromFloat(float64(r.Intn(300))).Round(p.currency)
+	}
+
+	city := r.Intn(len(p.cities))
+	first, last := firstNames[r.Intn(len(firstNames))], lastNames[r.Intn(len(lastNames))]
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    first + " " + last,
+			Phone:   "+" + p.callingCode + digits(p.phoneDigits)(r),
+			Zip:     p.zip(r),
+			City:    p.cities[city],
+			Address: fmt.Sprintf("%s street %d", lastNames[r.Intn(len(lastNames))], 1+r.Intn(200)),
+			Region:  p.regions[city],
+			Email:   strings.ToLower(first+"."+last) + "@example.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     p.currency,
+			Provider:     providers[r.Intn(len(providers))],
+			Amount:       goodsTotal.Add(deliveryCost).Add(customFee),
+			PaymentDt:    models.NewTimestamp(created.Add(time.Duration(r.Intn(600)) * time.Second).Truncate(time.Second)),
+			Bank:         banks[r.Intn(len(banks))],
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+			CustomFee:    customFee,
+		},
+		Items:           items,
+		Locale:          p.locale,
+		CustomerID:      g.token(8),
+		DeliveryService: services[r.Intn(len(services))],
+		ShardKey:        fmt.Sprint(r.Intn(10)),
+		SmID:            r.Intn(100),
+		DateCreated:     models.NewTimestamp(created.Truncate(time.Second)),
+		OofShard:        fmt.Sprint(1 + r.Intn(2)),
+	}
+}
+
+const alphanum = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// token returns a random lowercase alphanumeric string
+func (g *generator) token(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphanum[g.rand.Intn(len(alphanum))]
+	}
+	return string(b)
+}
+
+// digits makes random strings of n digits not starting with 0
+func digits(n int) func(r *rand.Rand) string {
+	return func(r *rand.Rand) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('0' + r.Intn(10))
+		}
+		b[0] = byte('1' + r.Intn(9))
+		return string(b)
+	}
+}
@@ -0,0 +1,195 @@
+// Command producer publishes orders to Kafka for testing: the orders of
+// files, JSON arrays or NDJSON streams, or randomly generated ones.
+//
+//	go run ./cmd/producer test_order.json
+//	go run ./cmd/producer -topic order-status testdata/status/paid.json
+//	go run ./cmd/producer -generate 1000 -invalid 0.1 -rate 50
+//
+// Kafka brokers and the topic default to the service configuration.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"L0/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// maxChunk is the most messages written at once
+	maxChunk = 500
+	// tick is how often a rate limited producer writes
+	tick = 100 * time.Millisecond
+	// caseHeader tells which case a generated message was made for
+	caseHeader = "generator-case"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("producer: ")
+
+	cfg := config.NewConfig()
+
+	brokers := flag.String("brokers", strings.Join(cfg.Kafka.Brokers, ","), "comma separated Kafka brokers")
+	topic := flag.String("topic", cfg.Kafka.Topic, "topic to publish to")
+	generate := flag.Int("generate", 0, "generate `N` orders instead of reading files")
+	invalid := flag.Float64("invalid", 0, "share of generated orders that are invalid, from 0 to 1")
+	seed := flag.Int64("seed", 0, "seed of the generator, 0 means random")
+	rate := flag.Float64("rate", 0, "messages per second, 0 means as fast as possible")
+	dryRun := flag.Bool("dry-run", false, "print messages as NDJSON instead of publishing them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file ...]\n\nFiles are read in order, \"-\" is the standard input.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var next func() (kafka.Message, error)
+	switch {
+	case *generate > 0 && flag.NArg() > 0:
+		log.Fatal("-generate and files are mutually exclusive")
+	case *generate > 0:
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+		next = generated(newGenerator(*seed, *invalid), *generate)
+	case flag.NArg() > 0:
+		next = fromFiles(newFileReader(flag.Args()))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var write func(ctx context.Context, msgs ...kafka.Message) error
+	if *dryRun {
+		write = printMessages
+	} else {
+		writer := &kafka.Writer{
+			Addr:                   kafka.TCP(strings.Split(*brokers, ",")...),
+			Topic:                  *topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			BatchSize:              maxChunk,
+			BatchTimeout:           10 * time.Millisecond,
+		}
+		defer writer.Close()
+		write = writer.WriteMessages
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	sent, err := produce(ctx, next, write, *rate)
+	log.Printf("%d messages produced in %s", sent, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// produce writes messages until next returns io.EOF. With a rate, messages
+// are written in chunks every tick so that the rate holds on average.
+func produce(ctx context.Context, next func() (kafka.Message, error), write func(context.Context, ...kafka.Message) error, rate float64) (int, error) {
+	start := time.Now()
+	sent := 0
+	chunk := make([]kafka.Message, 0, maxChunk)
+	for done := false; !done; {
+		limit := maxChunk
+		if rate > 0 {
+			due := int(time.Since(start).Seconds()*rate) + 1 - sent
+			if due <= 0 {
+				select {
+				case <-ctx.Done():
+					return sent, ctx.Err()
+				case <-time.After(min(tick, time.Duration(float64(time.Second)/rate))):
+				}
+				continue
+			}
+			limit = min(due, maxChunk)
+		}
+
+		chunk = chunk[:0]
+		for len(chunk) < limit {
+			m, err := next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				return sent, err
+			}
+			chunk = append(chunk, m)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		if err := write(ctx, chunk...); err != nil {
+			return sent, err
+		}
+		sent += len(chunk)
+	}
+	return sent, nil
+}
+
+// generated makes n messages of generated orders
+func generated(g *generator, n int) func() (kafka.Message, error) {
+	return func() (kafka.Message, error) {
+		if n == 0 {
+			return kafka.Message{}, io.EOF
+		}
+		n--
+		data, name, err := g.next()
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		return kafka.Message{
+			Key:     orderKey(data),
+			Value:   data,
+			Headers: []kafka.Header{{Key: caseHeader, Value: []byte(name)}},
+		}, nil
+	}
+}
+
+func fromFiles(f *fileReader) func() (kafka.Message, error) {
+	return func() (kafka.Message, error) {
+		data, err := f.next()
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		return kafka.Message{Key: orderKey(data), Value: data}, nil
+	}
+}
+
+// orderKey keys a message by its order_uid, so that messages of an order
+// land in one partition, as with KAFKA_ORDERING_KEY=key
+func orderKey(data []byte) []byte {
+	var msg struct {
+		OrderUID string `json:"order_uid"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.OrderUID == "" {
+		return nil
+	}
+	return []byte(msg.OrderUID)
+}
+
+func printMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		if _, err := fmt.Printf("%s\n", m.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
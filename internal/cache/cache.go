@@ -3,19 +3,59 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"L0/internal/config"
 	"L0/internal/logger"
+	"L0/internal/metrics"
 	"L0/internal/models"
+	"L0/internal/tracing"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("cache")
+
+// ErrNotFound is returned by Get for keys marked with SetNotFound
+var ErrNotFound = errors.New("order is cached as not found")
+
+// notFoundValue marks a missing order. It can't collide with a cached order,
+// which is never encoded as null.
+const notFoundValue = "null"
+
 type Cache interface {
 	Set(ctx context.Context, key string, value *models.Order) error
+	SetMany(ctx context.Context, orders []*models.Order) error
+	// AddMany caches only the orders that aren't cached yet, so it never
+	// replaces a fresher entry
+	AddMany(ctx context.Context, orders []*models.Order) error
+	// SetNotFound remembers for ttl that the order doesn't exist. A later Set
+	// of the same key replaces the mark.
+	SetNotFound(ctx context.Context, key string, ttl time.Duration) error
+	// Get returns nil on a miss and ErrNotFound for orders marked as missing
 	Get(ctx context.Context, key string) (*models.Order, error)
 	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+	// Close releases the connections of the cache
+	Close() error
+}
+
+// NewCache creates the cache selected by cfg.Cache.Backend
+func NewCache(cfg *config.Config, logger logger.Logger) (Cache, error) {
+	switch cfg.Cache.Backend {
+	case "redis":
+		return NewRedisCache(cfg.Redis, logger), nil
+	case "memory":
+		return NewMemoryCache(cfg.Cache, logger), nil
+	case "layered":
+		return NewLayeredCache(cfg, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %q", cfg.Cache.Backend)
+	}
 }
 
 type RedisCache struct {
@@ -26,6 +66,10 @@ type RedisCache struct {
 }
 
 func NewRedisCache(cfg config.RedisConfig, logger logger.Logger) Cache {
+	return newRedisCache(cfg, logger)
+}
+
+func newRedisCache(cfg config.RedisConfig, logger logger.Logger) *RedisCache {
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.Host + ":" + cfg.Port,
 		Password: cfg.Password,
@@ -39,13 +83,28 @@ func NewRedisCache(cfg config.RedisConfig, logger logger.Logger) Cache {
 	}
 }
 
-func (c *RedisCache) Set(ctx context.Context, key string, value *models.Order) error {
+// startSpan starts a client span for a single Redis operation
+func (c *RedisCache) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "RedisCache."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs,
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation.name", operation),
+		)...),
+	)
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value *models.Order) (err error) {
+	ctx, span := c.startSpan(ctx, "set", attribute.String("order.uid", key))
+	defer func() { tracing.End(span, err) }()
+
 	b, err := json.Marshal(value)
 	if err != nil {
 		c.logger.Errorf("Failed to marshal order for cache: %v", err)
 		return err
 	}
 	err = c.client.Set(ctx, c.prefix+key, b, c.ttl).Err()
+	metrics.CacheRequests.WithLabelValues("redis", "set", metrics.Status(err)).Inc()
 	if err != nil {
 		c.logger.Errorf("Failed to set order in cache: %v", err)
 	} else {
@@ -54,27 +113,105 @@ func (c *RedisCache) Set(ctx context.Context, key string, value *models.Order) e
 	return err
 }
 
-func (c *RedisCache) Get(ctx context.Context, key string) (*models.Order, error) {
+// SetMany caches orders by their order_uid in a single pipeline
+func (c *RedisCache) SetMany(ctx context.Context, orders []*models.Order) (err error) {
+	ctx, span := c.startSpan(ctx, "set_many", attribute.Int("orders.count", len(orders)))
+	defer func() { tracing.End(span, err) }()
+
+	pipe := c.client.Pipeline()
+	for _, order := range orders {
+		b, err := json.Marshal(order)
+		if err != nil {
+			c.logger.Errorf("Failed to marshal order for cache: %v", err)
+			return err
+		}
+		pipe.Set(ctx, c.prefix+order.OrderUID, b, c.ttl)
+	}
+
+	_, err = pipe.Exec(ctx)
+	metrics.CacheRequests.WithLabelValues("redis", "set_many", metrics.Status(err)).Inc()
+	if err != nil {
+		c.logger.Errorf("Failed to set orders in cache: %v", err)
+	} else {
+		c.logger.Infof("Orders cached successfully: %d", len(orders))
+	}
+	return err
+}
+
+func (c *RedisCache) AddMany(ctx context.Context, orders []*models.Order) (err error) {
+	ctx, span := c.startSpan(ctx, "add_many", attribute.Int("orders.count", len(orders)))
+	defer func() { tracing.End(span, err) }()
+
+	pipe := c.client.Pipeline()
+	for _, order := range orders {
+		b, err := json.Marshal(order)
+		if err != nil {
+			c.logger.Errorf("Failed to marshal order for cache: %v", err)
+			return err
+		}
+		pipe.SetNX(ctx, c.prefix+order.OrderUID, b, c.ttl)
+	}
+
+	_, err = pipe.Exec(ctx)
+	metrics.CacheRequests.WithLabelValues("redis", "add_many", metrics.Status(err)).Inc()
+	if err != nil {
+		c.logger.Errorf("Failed to add orders to cache: %v", err)
+	}
+	return err
+}
+
+func (c *RedisCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) (err error) {
+	ctx, span := c.startSpan(ctx, "set_not_found", attribute.String("order.uid", key))
+	defer func() { tracing.End(span, err) }()
+
+	err = c.client.Set(ctx, c.prefix+key, notFoundValue, ttl).Err()
+	metrics.CacheRequests.WithLabelValues("redis", "set_not_found", metrics.Status(err)).Inc()
+	if err != nil {
+		c.logger.Errorf("Failed to cache missing order: %v", err)
+	}
+	return err
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (_ *models.Order, err error) {
+	ctx, span := c.startSpan(ctx, "get", attribute.String("order.uid", key))
+	defer func() { tracing.End(span, err) }()
+
 	val, err := c.client.Get(ctx, c.prefix+key).Result()
 	if err == redis.Nil {
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		metrics.CacheRequests.WithLabelValues("redis", "get", "miss").Inc()
 		c.logger.Debugf("Order not found in cache: %s", key)
 		return nil, nil
 	}
 	if err != nil {
+		metrics.CacheRequests.WithLabelValues("redis", "get", "error").Inc()
 		c.logger.Errorf("Failed to get order from cache: %v", err)
 		return nil, err
 	}
+	if val == notFoundValue {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		metrics.CacheRequests.WithLabelValues("redis", "get", "not_found").Inc()
+		c.logger.Debugf("Order cached as not found: %s", key)
+		return nil, ErrNotFound
+	}
 	var order models.Order
 	if err := json.Unmarshal([]byte(val), &order); err != nil {
+		metrics.CacheRequests.WithLabelValues("redis", "get", "error").Inc()
 		c.logger.Errorf("Failed to unmarshal order from cache: %v", err)
 		return nil, err
 	}
+	span.SetAttributes(attribute.Bool("cache.hit", true))
+	metrics.CacheRequests.WithLabelValues("redis", "get", "hit").Inc()
 	c.logger.Infof("Order retrieved from cache: %s", key)
 	return &order, nil
 }
 
-func (c *RedisCache) Delete(ctx context.Context, key string) error {
-	err := c.client.Del(ctx, c.prefix+key).Err()
+func (c *RedisCache) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := c.startSpan(ctx, "delete", attribute.String("order.uid", key))
+	defer func() { tracing.End(span, err) }()
+
+	err = c.client.Del(ctx, c.prefix+key).Err()
+	metrics.CacheRequests.WithLabelValues("redis", "delete", metrics.Status(err)).Inc()
 	if err != nil {
 		c.logger.Errorf("Failed to delete order from cache: %v", err)
 	} else {
@@ -82,3 +219,11 @@ func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	}
 	return err
 }
+
+func (c *RedisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"L0/internal/config"
+	"L0/internal/logger"
+	"L0/internal/metrics"
+	"L0/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// resubscribeDelay is the pause before receiving again after the
+// invalidation subscription failed
+const resubscribeDelay = time.Second
+
+// LayeredCache keeps hot orders in process memory in front of Redis. Writes go
+// to both tiers and are announced on a Redis pub/sub channel, so the other
+// instances drop their local copies instead of serving stale orders.
+type LayeredCache struct {
+	local   *MemoryCache
+	remote  *RedisCache
+	channel string
+	source  string // identifies this instance in invalidation messages
+
+	sub    *redis.PubSub
+	cancel context.CancelFunc // stops listen
+	done   chan struct{}      // closed when listen returns
+
+	// generation changes on every local write or invalidation. A value read
+	// from Redis is only kept locally if nothing changed while it was read.
+	mu         sync.Mutex
+	generation uint64
+
+	logger logger.Logger
+}
+
+type invalidation struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+func NewLayeredCache(cfg *config.Config, logger logger.Logger) Cache {
+	c := &LayeredCache{
+		local:   newMemoryCache("local", cfg.Cache, logger),
+		remote:  newRedisCache(cfg.Redis, logger),
+		channel: cfg.Cache.InvalidationChannel,
+		source:  instanceID(),
+		logger:  logger.WithField("component", "layered_cache"),
+		done:    make(chan struct{}),
+	}
+
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+	c.sub = c.remote.client.Subscribe(ctx, c.channel)
+	go c.listen(ctx)
+	return c
+}
+
+func (c *LayeredCache) Set(ctx context.Context, key string, value *models.Order) error {
+	if err := c.remote.Set(ctx, key, value); err != nil {
+		// The remote copy may be outdated now, so nobody must keep theirs
+		c.invalidate(ctx, key)
+		return err
+	}
+	c.mu.Lock()
+	c.generation++
+	c.local.Set(ctx, key, value)
+	c.mu.Unlock()
+
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *LayeredCache) SetMany(ctx context.Context, orders []*models.Order) error {
+	keys := make([]string, len(orders))
+	for i, order := range orders {
+		keys[i] = order.OrderUID
+	}
+
+	if err := c.remote.SetMany(ctx, orders); err != nil {
+		c.invalidate(ctx, keys...)
+		return err
+	}
+	c.mu.Lock()
+	c.generation++
+	c.local.SetMany(ctx, orders)
+	c.mu.Unlock()
+
+	c.publish(ctx, keys...)
+	return nil
+}
+
+// AddMany only fills Redis. Nothing is replaced, so there is nothing to
+// invalidate, and the local tier picks the orders up when they are read.
+func (c *LayeredCache) AddMany(ctx context.Context, orders []*models.Order) error {
+	return c.remote.AddMany(ctx, orders)
+}
+
+func (c *LayeredCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	if err := c.remote.SetNotFound(ctx, key, ttl); err != nil {
+		c.invalidate(ctx, key)
+		return err
+	}
+	c.mu.Lock()
+	c.generation++
+	c.local.SetNotFound(ctx, key, ttl)
+	c.mu.Unlock()
+
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *LayeredCache) Get(ctx context.Context, key string) (*models.Order, error) {
+	if order, err := c.local.Get(ctx, key); order != nil || err == ErrNotFound {
+		return order, err
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	order, err := c.remote.Get(ctx, key)
+	if err != nil || order == nil {
+		return order, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.local.Set(ctx, key, order)
+	}
+	c.mu.Unlock()
+
+	return order, nil
+}
+
+func (c *LayeredCache) Delete(ctx context.Context, key string) error {
+	err := c.remote.Delete(ctx, key)
+	c.invalidate(ctx, key)
+	return err
+}
+
+func (c *LayeredCache) Ping(ctx context.Context) error {
+	return c.remote.Ping(ctx)
+}
+
+// Close stops listening for invalidations and closes the Redis connections
+func (c *LayeredCache) Close() error {
+	c.cancel()
+	if err := c.sub.Close(); err != nil {
+		c.logger.Errorf("Error closing invalidation subscription: %v", err)
+	}
+	<-c.done
+	return c.remote.Close()
+}
+
+// invalidate drops the keys locally and on the other instances
+func (c *LayeredCache) invalidate(ctx context.Context, keys ...string) {
+	c.drop(keys)
+	c.publish(ctx, keys...)
+}
+
+func (c *LayeredCache) drop(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		c.local.Delete(context.Background(), key)
+	}
+}
+
+func (c *LayeredCache) publish(ctx context.Context, keys ...string) {
+	b, err := json.Marshal(invalidation{Source: c.source, Keys: keys})
+	if err != nil {
+		c.logger.Errorf("Failed to marshal invalidation: %v", err)
+		return
+	}
+	if err := c.remote.client.Publish(ctx, c.channel, b).Err(); err != nil {
+		c.logger.Errorf("Failed to publish invalidation of %d keys: %v", len(keys), err)
+		return
+	}
+	metrics.CacheInvalidations.WithLabelValues("sent").Add(float64(len(keys)))
+}
+
+// listen applies invalidations published by the other instances until the
+// cache is closed. The subscription reconnects on its own; invalidations sent
+// while it was down are lost, so the local tier is dropped on every
+// resubscription.
+func (c *LayeredCache) listen(ctx context.Context) {
+	defer close(c.done)
+
+	subscribed := false
+	for {
+		msg, err := c.sub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.logger.Warnf("Invalidation subscription failed: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			if subscribed {
+				c.logger.Warnf("Invalidation subscription restored, dropping local cache")
+				c.mu.Lock()
+				c.generation++
+				c.local.purge()
+				c.mu.Unlock()
+			}
+			subscribed = true
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				c.logger.Errorf("Failed to unmarshal invalidation: %v", err)
+				continue
+			}
+			if inv.Source == c.source {
+				continue
+			}
+			c.drop(inv.Keys)
+			metrics.CacheInvalidations.WithLabelValues("received").Add(float64(len(inv.Keys)))
+		}
+	}
+}
+
+func instanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
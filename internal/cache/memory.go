@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"L0/internal/config"
+	"L0/internal/logger"
+	"L0/internal/metrics"
+	"L0/internal/models"
+)
+
+// MemoryCache is an in-process LRU cache bounded by the number of entries and
+// their encoded size. Orders are stored as JSON, so callers never share an
+// instance with the cache and the byte limit reflects the real footprint.
+type MemoryCache struct {
+	mu         sync.Mutex
+	name       string
+	entries    *list.List // front is the most recently used
+	index      map[string]*list.Element
+	bytes      int
+	maxEntries int
+	maxBytes   int
+	ttl        time.Duration
+	stats      MemoryStats
+	logger     logger.Logger
+}
+
+// MemoryStats is a snapshot of the cache state and counters
+type MemoryStats struct {
+	Entries     int
+	Bytes       int
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // entries dropped to stay within the limits
+	Expirations uint64 // entries dropped because their TTL passed
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero means the entry doesn't expire
+}
+
+func NewMemoryCache(cfg config.CacheConfig, logger logger.Logger) Cache {
+	return newMemoryCache("memory", cfg, logger)
+}
+
+func newMemoryCache(name string, cfg config.CacheConfig, logger logger.Logger) *MemoryCache {
+	return &MemoryCache{
+		name:       name,
+		entries:    list.New(),
+		index:      make(map[string]*list.Element),
+		maxEntries: cfg.MemoryMaxEntries,
+		maxBytes:   cfg.MemoryMaxBytes,
+		ttl:        time.Duration(cfg.MemoryTTL) * time.Second,
+		logger:     logger.WithField("component", name+"_cache"),
+	}
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value *models.Order) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		c.logger.Errorf("Failed to marshal order for cache: %v", err)
+		return err
+	}
+
+	c.mu.Lock()
+	c.set(key, b, c.ttl)
+	c.mu.Unlock()
+
+	metrics.CacheRequests.WithLabelValues(c.name, "set", "ok").Inc()
+	c.logger.Debugf("Order cached successfully: %s", key)
+	return nil
+}
+
+func (c *MemoryCache) SetMany(ctx context.Context, orders []*models.Order) error {
+	values, err := c.encode(orders)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	for i, order := range orders {
+		c.set(order.OrderUID, values[i], c.ttl)
+	}
+	c.mu.Unlock()
+
+	metrics.CacheRequests.WithLabelValues(c.name, "set_many", "ok").Inc()
+	c.logger.Debugf("Orders cached successfully: %d", len(orders))
+	return nil
+}
+
+func (c *MemoryCache) AddMany(ctx context.Context, orders []*models.Order) error {
+	values, err := c.encode(orders)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	for i, order := range orders {
+		if el, ok := c.index[order.OrderUID]; ok && !el.Value.(*memoryEntry).expired(now) {
+			continue
+		}
+		c.set(order.OrderUID, values[i], c.ttl)
+	}
+	c.mu.Unlock()
+
+	metrics.CacheRequests.WithLabelValues(c.name, "add_many", "ok").Inc()
+	return nil
+}
+
+func (c *MemoryCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	c.mu.Lock()
+	c.set(key, []byte(notFoundValue), ttl)
+	c.mu.Unlock()
+
+	metrics.CacheRequests.WithLabelValues(c.name, "set_not_found", "ok").Inc()
+	return nil
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (*models.Order, error) {
+	c.mu.Lock()
+	value, ok := c.get(key)
+	c.mu.Unlock()
+
+	if !ok {
+		metrics.CacheRequests.WithLabelValues(c.name, "get", "miss").Inc()
+		return nil, nil
+	}
+	if string(value) == notFoundValue {
+		metrics.CacheRequests.WithLabelValues(c.name, "get", "not_found").Inc()
+		return nil, ErrNotFound
+	}
+
+	var order models.Order
+	if err := json.Unmarshal(value, &order); err != nil {
+		metrics.CacheRequests.WithLabelValues(c.name, "get", "error").Inc()
+		c.logger.Errorf("Failed to unmarshal order from cache: %v", err)
+		return nil, err
+	}
+	metrics.CacheRequests.WithLabelValues(c.name, "get", "hit").Inc()
+	return &order, nil
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	if el, ok := c.index[key]; ok {
+		c.remove(el)
+		c.observeSize()
+	}
+	c.mu.Unlock()
+
+	metrics.CacheRequests.WithLabelValues(c.name, "delete", "ok").Inc()
+	return nil
+}
+
+func (c *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// Stats returns the current size and counters of the cache
+func (c *MemoryCache) Stats() MemoryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// set stores the value and evicts the least recently used entries until the
+// cache fits its limits again. Must be called with mu held.
+func (c *MemoryCache) set(key string, value []byte, ttl time.Duration) {
+	// A value that can never fit would only flush the whole cache
+	if c.maxBytes > 0 && len(value) > c.maxBytes {
+		if el, ok := c.index[key]; ok {
+			c.remove(el)
+		}
+		c.logger.Warnf("Order is larger than the cache, not cached: %s (%d bytes)", key, len(value))
+		c.observeSize()
+		return
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.index[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		c.bytes += len(value) - len(entry.value)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.entries.MoveToFront(el)
+	} else {
+		c.index[key] = c.entries.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+		c.bytes += len(value)
+	}
+
+	for c.overLimit() {
+		oldest := c.entries.Back()
+		if oldest.Value.(*memoryEntry).expired(time.Now()) {
+			c.stats.Expirations++
+			metrics.CacheEvictions.WithLabelValues(c.name, "expired").Inc()
+		} else {
+			c.stats.Evictions++
+			metrics.CacheEvictions.WithLabelValues(c.name, "size").Inc()
+		}
+		c.remove(oldest)
+	}
+	c.observeSize()
+}
+
+// get returns the value and marks it as recently used. Expired entries are
+// removed lazily here. Must be called with mu held.
+func (c *MemoryCache) get(key string) ([]byte, bool) {
+	el, ok := c.index[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		c.remove(el)
+		c.stats.Expirations++
+		c.stats.Misses++
+		metrics.CacheEvictions.WithLabelValues(c.name, "expired").Inc()
+		c.observeSize()
+		return nil, false
+	}
+
+	c.entries.MoveToFront(el)
+	c.stats.Hits++
+	return entry.value, true
+}
+
+func (c *MemoryCache) encode(orders []*models.Order) ([][]byte, error) {
+	values := make([][]byte, len(orders))
+	for i, order := range orders {
+		b, err := json.Marshal(order)
+		if err != nil {
+			c.logger.Errorf("Failed to marshal order for cache: %v", err)
+			return nil, err
+		}
+		values[i] = b
+	}
+	return values, nil
+}
+
+// purge drops every entry
+func (c *MemoryCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.Init()
+	c.index = make(map[string]*list.Element)
+	c.bytes = 0
+	c.observeSize()
+}
+
+func (c *MemoryCache) overLimit() bool {
+	if c.entries.Len() == 0 {
+		return false
+	}
+	return (c.maxEntries > 0 && c.entries.Len() > c.maxEntries) ||
+		(c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+	entry := c.entries.Remove(el).(*memoryEntry)
+	delete(c.index, entry.key)
+	c.bytes -= len(entry.value)
+}
+
+func (c *MemoryCache) observeSize() {
+	metrics.CacheEntries.WithLabelValues(c.name).Set(float64(c.entries.Len()))
+	metrics.CacheBytes.WithLabelValues(c.name).Set(float64(c.bytes))
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
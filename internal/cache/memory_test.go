@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"L0/internal/config"
+	"L0/internal/logger"
+	"L0/internal/models"
+)
+
+func testCache(t *testing.T, cfg config.CacheConfig) *MemoryCache {
+	t.Helper()
+	return newMemoryCache("memory", cfg, logger.NewLogger())
+}
+
+func order(uid string) *models.Order {
+	return &models.Order{OrderUID: uid, TrackNumber: "WBILMTESTTRACK"}
+}
+
+// cached tells whether Get returns the order
+func cached(t *testing.T, c *MemoryCache, uid string) bool {
+	t.Helper()
+	got, err := c.Get(context.Background(), uid)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", uid, err)
+	}
+	if got != nil && got.OrderUID != uid {
+		t.Fatalf("Get(%q) returned order %q", uid, got.OrderUID)
+	}
+	return got != nil
+}
+
+func TestMemoryCacheEvictsByEntries(t *testing.T) {
+	ctx := context.Background()
+	c := testCache(t, config.CacheConfig{MemoryMaxEntries: 2})
+
+	for _, uid := range []string{"a", "b"} {
+		if err := c.Set(ctx, uid, order(uid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reading a makes b the least recently used
+	if !cached(t, c, "a") {
+		t.Fatal("a is not cached")
+	}
+	if err := c.Set(ctx, "c", order("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	if cached(t, c, "b") {
+		t.Error("b is cached, want it evicted")
+	}
+	if !cached(t, c, "a") || !cached(t, c, "c") {
+		t.Error("a and c must stay cached")
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("stats = %+v, want 2 entries and 1 eviction", stats)
+	}
+}
+
+func TestMemoryCacheEvictsByBytes(t *testing.T) {
+	ctx := context.Background()
+	b, err := json.Marshal(order("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(b)
+	c := testCache(t, config.CacheConfig{MemoryMaxBytes: 2*size + size/2})
+
+	for _, uid := range []string{"a", "b", "c"} {
+		if err := c.Set(ctx, uid, order(uid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cached(t, c, "a") {
+		t.Error("a is cached, want it evicted")
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Bytes != 2*size {
+		t.Errorf("stats = %+v, want 2 entries of %d bytes", stats, 2*size)
+	}
+}
+
+func TestMemoryCacheSkipsOversizedValue(t *testing.T) {
+	ctx := context.Background()
+	c := testCache(t, config.CacheConfig{MemoryMaxBytes: 64})
+	if err := c.SetNotFound(ctx, "a", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	big := order("a")
+	big.Items = make([]models.Item, 10)
+	if err := c.Set(ctx, "a", big); err != nil {
+		t.Fatal(err)
+	}
+
+	if cached(t, c, "a") {
+		t.Error("oversized order is cached")
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("stats = %+v, want an empty cache", stats)
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	c := testCache(t, config.CacheConfig{})
+	c.ttl = 20 * time.Millisecond
+
+	if err := c.Set(ctx, "a", order("a")); err != nil {
+		t.Fatal(err)
+	}
+	if !cached(t, c, "a") {
+		t.Fatal("a is not cached")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if cached(t, c, "a") {
+		t.Error("a is cached after its TTL")
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Expirations != 1 {
+		t.Errorf("stats = %+v, want no entries and 1 expiration", stats)
+	}
+}
+
+func TestMemoryCacheAddManyKeepsFreshEntries(t *testing.T) {
+	ctx := context.Background()
+	c := testCache(t, config.CacheConfig{})
+
+	fresh := order("a")
+	fresh.TrackNumber = "FRESH"
+	if err := c.Set(ctx, "a", fresh); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddMany(ctx, []*models.Order{order("a"), order("b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TrackNumber != "FRESH" {
+		t.Errorf("AddMany replaced a cached order, track number %q", got.TrackNumber)
+	}
+	if !cached(t, c, "b") {
+		t.Error("b is not cached")
+	}
+}
+
+func TestMemoryCacheNotFound(t *testing.T) {
+	ctx := context.Background()
+	c := testCache(t, config.CacheConfig{})
+
+	if err := c.SetNotFound(ctx, "a", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing order: err = %v, want ErrNotFound", err)
+	}
+
+	// A negative entry expires on its own TTL
+	time.Sleep(40 * time.Millisecond)
+	if cached(t, c, "a") {
+		t.Error("a is cached after the negative TTL")
+	}
+
+	// and is replaced by a later Set
+	if err := c.SetNotFound(ctx, "b", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "b", order("b")); err != nil {
+		t.Fatal(err)
+	}
+	if !cached(t, c, "b") {
+		t.Error("Set didn't replace the negative entry")
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +12,10 @@ type Config struct {
 	Postgres PostgresConfig
 	Kafka    KafkaConfig
 	Redis    RedisConfig
+	Cache    CacheConfig
+	Orders   OrdersConfig
+	Outbox   OutboxConfig
+	Tracing  TracingConfig
 }
 
 type PostgresConfig struct {
@@ -22,9 +27,19 @@ type PostgresConfig struct {
 }
 
 type KafkaConfig struct {
-	Brokers []string
-	Topic   string
-	GroupID string
+	Brokers         []string
+	Topic           string
+	StatusTopic     string // status-change events, empty disables them
+	GroupID         string
+	DLQTopic        string
+	MaxRetries      int // attempts per message before it is dead-lettered
+	RetryDelay      int // milliseconds, initial backoff
+	RetryMaxDelay   int // milliseconds
+	RetryMaxElapsed int // milliseconds, 0 means unlimited
+	Concurrency     int
+	OrderingKey     string // partition or key
+	BatchSize       int    // 0 disables batch mode
+	BatchTimeout    int    // milliseconds
 }
 
 type RedisConfig struct {
@@ -36,6 +51,40 @@ type RedisConfig struct {
 	TTL      int // seconds
 }
 
+type CacheConfig struct {
+	Backend             string // redis, memory or layered
+	MemoryMaxEntries    int
+	MemoryMaxBytes      int
+	MemoryTTL           int    // seconds, 0 means entries don't expire
+	InvalidationChannel string // Redis pub/sub channel of the layered cache
+	NegativeTTL         int    // seconds a missing order is remembered, 0 disables
+	Warmup              bool
+	WarmupLimit         int // most recent orders to warm up, 0 means all
+	WarmupDays          int // only orders created in the last days, 0 means any
+	WarmupBatchSize     int
+}
+
+type OrdersConfig struct {
+	ConflictPolicy string            // reject, last_write_wins or newer_wins
+	RuleTolerance  float64           // allowed rounding difference of amounts, 0 means exact
+	RuleSeverity   map[string]string // business rule name to reject or warn
+}
+
+type OutboxConfig struct {
+	Topic        string // "order accepted" events, empty disables the outbox
+	PollInterval int    // milliseconds between relay runs
+	BatchSize    int    // rows published at once
+	Retention    int    // seconds sent rows are kept before cleanup
+}
+
+type TracingConfig struct {
+	Exporter     string // none, stdout or otlp
+	OTLPEndpoint string // host:port of an OTLP/HTTP collector
+	OTLPInsecure bool
+	ServiceName  string
+	SampleRatio  float64
+}
+
 func NewConfig() *Config {
 	godotenv.Load()
 
@@ -57,9 +106,19 @@ func NewConfig() *Config {
 			DBName:   getEnv("POSTGRES_DB", "orders"),
 		},
 		Kafka: KafkaConfig{
-			Brokers: []string{getEnv("KAFKA_BROKERS", "localhost:9092")},
-			Topic:   getEnv("KAFKA_TOPIC", "orders"),
-			GroupID: getEnv("KAFKA_GROUP_ID", "orders-service"),
+			Brokers:         []string{getEnv("KAFKA_BROKERS", "localhost:9092")},
+			Topic:           getEnv("KAFKA_TOPIC", "orders"),
+			StatusTopic:     getEnv("KAFKA_STATUS_TOPIC", "order-status"),
+			GroupID:         getEnv("KAFKA_GROUP_ID", "orders-service"),
+			DLQTopic:        getEnv("KAFKA_DLQ_TOPIC", "orders-dlq"),
+			MaxRetries:      getEnvInt("KAFKA_MAX_RETRIES", 5),
+			RetryDelay:      getEnvInt("KAFKA_RETRY_DELAY_MS", 200),
+			RetryMaxDelay:   getEnvInt("KAFKA_RETRY_MAX_DELAY_MS", 10000),
+			RetryMaxElapsed: getEnvInt("KAFKA_RETRY_MAX_ELAPSED_MS", 60000),
+			Concurrency:     getEnvInt("KAFKA_CONCURRENCY", 1),
+			OrderingKey:     getEnv("KAFKA_ORDERING_KEY", "partition"),
+			BatchSize:       getEnvInt("KAFKA_BATCH_SIZE", 0),
+			BatchTimeout:    getEnvInt("KAFKA_BATCH_TIMEOUT_MS", 500),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -69,6 +128,36 @@ func NewConfig() *Config {
 			Prefix:   getEnv("REDIS_PREFIX", "order:"),
 			TTL:      redisTTL,
 		},
+		Cache: CacheConfig{
+			Backend:             getEnv("CACHE_BACKEND", "redis"),
+			MemoryMaxEntries:    getEnvInt("CACHE_MEMORY_MAX_ENTRIES", 10000),
+			MemoryMaxBytes:      getEnvInt("CACHE_MEMORY_MAX_BYTES", 64<<20),
+			MemoryTTL:           getEnvInt("CACHE_MEMORY_TTL", 3600),
+			InvalidationChannel: getEnv("CACHE_INVALIDATION_CHANNEL", "order-cache-invalidation"),
+			NegativeTTL:         getEnvInt("CACHE_NEGATIVE_TTL", 5),
+			Warmup:              getEnv("CACHE_WARMUP", "true") == "true",
+			WarmupLimit:         getEnvInt("CACHE_WARMUP_LIMIT", 10000),
+			WarmupDays:          getEnvInt("CACHE_WARMUP_DAYS", 0),
+			WarmupBatchSize:     getEnvInt("CACHE_WARMUP_BATCH_SIZE", 500),
+		},
+		Orders: OrdersConfig{
+			ConflictPolicy: getEnv("ORDER_CONFLICT_POLICY", "reject"),
+			RuleTolerance:  getEnvFloat("ORDER_RULE_TOLERANCE", 0),
+			RuleSeverity:   getEnvMap("ORDER_RULE_SEVERITY"),
+		},
+		Outbox: OutboxConfig{
+			Topic:        getEnv("OUTBOX_TOPIC", "orders-accepted"),
+			PollInterval: getEnvInt("OUTBOX_POLL_INTERVAL_MS", 1000),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			Retention:    getEnvInt("OUTBOX_RETENTION", 86400),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: getEnv("TRACING_OTLP_INSECURE", "true") == "true",
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "l0"),
+			SampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		},
 	}
 }
 
@@ -78,3 +167,31 @@ func getEnv(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+func getEnvInt(key string, defaultVal int) int {
+	val := defaultVal
+	if str := getEnv(key, ""); str != "" {
+		fmt.Sscanf(str, "%d", &val)
+	}
+	return val
+}
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	val := defaultVal
+	if str := getEnv(key, ""); str != "" {
+		fmt.Sscanf(str, "%g", &val)
+	}
+	return val
+}
+
+// getEnvMap parses a comma separated list of key=value pairs
+func getEnvMap(key string) map[string]string {
+	val := make(map[string]string)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok {
+			val[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return val
+}
@@ -0,0 +1,26 @@
+package kafka
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// backoff computes jittered exponential delays between retries
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+}
+
+// delay returns the pause before the given retry (starting from 1).
+// The result is picked at random from [d/2, d], where d doubles every retry up to max.
+func (b backoff) delay(retry int) time.Duration {
+	d := float64(b.initial) * math.Pow(b.multiplier, float64(retry-1))
+	if d > float64(b.max) {
+		d = float64(b.max)
+	}
+
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
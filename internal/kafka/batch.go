@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"L0/internal/metrics"
+	"L0/internal/models"
+	"L0/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startBatch consumes messages in batches of up to batchSize messages or
+// whatever arrived within batchTimeout, and commits each batch at once
+func (c *Consumer) startBatch(ctx context.Context) error {
+	c.logger.Infof("Starting Kafka consumer in batch mode: size=%d, timeout=%s",
+		c.batchSize, c.batchTimeout)
+
+	for {
+		batch, err := c.fetchBatch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.logger.Info("Kafka consumer stopped")
+				return ctx.Err()
+			}
+			c.logger.Errorf("Error reading message: %v", err)
+			continue
+		}
+
+		if err := c.handleBatch(ctx, batch); err != nil {
+			c.logger.Errorf("Error handling batch: %v", err)
+			// Only reached on shutdown, the batch will be redelivered after restart
+			continue
+		}
+
+		// Commit offsets only after every message was processed or dead-lettered
+		if err := c.reader.CommitMessages(ctx, batch...); err != nil {
+			c.logger.Errorf("Error committing batch: %v", err)
+		} else {
+			for _, m := range batch {
+				metrics.MessagesCommitted.WithLabelValues(m.Topic, metrics.Partition(m.Partition)).Inc()
+			}
+			c.logger.Infof("Batch committed: %d messages", len(batch))
+		}
+	}
+}
+
+// fetchBatch blocks until the first message arrives, then collects more
+// until the batch is full or the batch timeout expires
+func (c *Consumer) fetchBatch(ctx context.Context) ([]kafka.Message, error) {
+	first, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	observeFetched(first)
+	batch := []kafka.Message{first}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.batchTimeout)
+	defer cancel()
+
+	for len(batch) < c.batchSize {
+		m, err := c.reader.FetchMessage(fetchCtx)
+		if err != nil {
+			break
+		}
+		observeFetched(m)
+		batch = append(batch, m)
+	}
+
+	c.logger.Infof("Received batch from Kafka: %d messages", len(batch))
+	return batch, nil
+}
+
+// handleBatch saves the orders of the batch in bulk. Messages that fail go
+// through the single-message path, which retries or dead-letters them
+// without affecting the rest of the batch. Retried messages land after the
+// rest of the batch, so batch mode is meant for backfills rather than
+// streams of updates to the same orders. Status changes are applied one by
+// one after the orders of the batch are saved.
+func (c *Consumer) handleBatch(ctx context.Context, batch []kafka.Message) (err error) {
+	start := time.Now()
+
+	// A batch has many parents, so the producers' traces are linked instead
+	links := make([]trace.Link, len(batch))
+	for i, m := range batch {
+		links[i] = trace.LinkFromContext(messageContext(ctx, m))
+	}
+	ctx, span := tracer.Start(ctx, "Consumer.handleBatch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.Int("messaging.batch.message_count", len(batch)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	orders := make([]*models.Order, 0, len(batch))
+	orderMsgs := make([]kafka.Message, 0, len(batch))
+	origins := make(map[*models.Order]models.Origin, len(batch))
+	failed := make([]kafka.Message, 0)
+	statusChanges := make([]kafka.Message, 0)
+
+	for _, m := range batch {
+		if c.isStatusChange(m) {
+			statusChanges = append(statusChanges, m)
+			continue
+		}
+		var order models.Order
+		if err := json.Unmarshal(m.Value, &order); err != nil {
+			c.logger.Errorf("Failed to unmarshal order: partition=%d, offset=%d: %v",
+				m.Partition, m.Offset, err)
+			failed = append(failed, m)
+			continue
+		}
+		orders = append(orders, &order)
+		orderMsgs = append(orderMsgs, m)
+		origins[&order] = messageOrigin(m)
+	}
+
+	if len(orders) > 0 {
+		_, errs := c.svc.CreateOrders(models.WithOrderOrigins(ctx, origins), orders)
+		for i, err := range errs {
+			if err != nil {
+				failed = append(failed, orderMsgs[i])
+			}
+		}
+	}
+
+	for _, m := range append(failed, statusChanges...) {
+		if err := c.handleMessage(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	c.logger.Infof("Batch processed in %s: %d messages, %d orders handled individually, %d status changes",
+		time.Since(start), len(batch), len(failed), len(statusChanges))
+	return nil
+}
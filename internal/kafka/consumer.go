@@ -3,41 +3,130 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
 
 	"L0/internal/config"
 	"L0/internal/logger"
+	"L0/internal/metrics"
 	"L0/internal/models"
 	"L0/internal/service"
+	"L0/internal/tracing"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// workerQueueSize is how many fetched messages may wait for each worker
+const workerQueueSize = 64
+
 type Consumer struct {
-	reader *kafka.Reader
-	svc    service.OrderService
-	logger logger.Logger
+	brokers      []string
+	topic        string
+	statusTopic  string
+	reader       *kafka.Reader
+	dlqWriter    *kafka.Writer
+	svc          service.OrderService
+	maxRetries   int
+	maxElapsed   time.Duration
+	backoff      backoff
+	concurrency  int
+	byKey        bool
+	offsets      *offsetTracker
+	commitMu     sync.Mutex
+	batchSize    int
+	batchTimeout time.Duration
+	logger       logger.Logger
 }
 
 func NewConsumer(cfg *config.Config, svc service.OrderService, logger logger.Logger) *Consumer {
+	// Orders and their status changes are read by the same group, so one
+	// worker handles both for an order when messages are keyed by order_uid
+	topics := []string{cfg.Kafka.Topic}
+	if cfg.Kafka.StatusTopic != "" {
+		topics = append(topics, cfg.Kafka.StatusTopic)
+	}
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  cfg.Kafka.Brokers,
-		Topic:    cfg.Kafka.Topic,
-		GroupID:  cfg.Kafka.GroupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
+		Brokers:     cfg.Kafka.Brokers,
+		GroupTopics: topics,
+		GroupID:     cfg.Kafka.GroupID,
+		MinBytes:    10e3, // 10KB
+		MaxBytes:    10e6, // 10MB
 	})
 
+	dlqWriter := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Kafka.Brokers...),
+		Topic:                  cfg.Kafka.DLQTopic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+
+	maxRetries := cfg.Kafka.MaxRetries
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
+	concurrency := cfg.Kafka.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	return &Consumer{
-		reader: reader,
-		svc:    svc,
-		logger: logger.WithField("component", "kafka_consumer"),
+		brokers:     cfg.Kafka.Brokers,
+		topic:       cfg.Kafka.Topic,
+		statusTopic: cfg.Kafka.StatusTopic,
+		reader:      reader,
+		dlqWriter:   dlqWriter,
+		svc:         svc,
+		maxRetries:  maxRetries,
+		maxElapsed:  time.Duration(cfg.Kafka.RetryMaxElapsed) * time.Millisecond,
+		backoff: backoff{
+			initial:    time.Duration(cfg.Kafka.RetryDelay) * time.Millisecond,
+			max:        time.Duration(cfg.Kafka.RetryMaxDelay) * time.Millisecond,
+			multiplier: 2,
+		},
+		concurrency:  concurrency,
+		byKey:        cfg.Kafka.OrderingKey == "key",
+		offsets:      newOffsetTracker(),
+		batchSize:    cfg.Kafka.BatchSize,
+		batchTimeout: time.Duration(cfg.Kafka.BatchTimeout) * time.Millisecond,
+		logger:       logger.WithField("component", "kafka_consumer"),
 	}
 }
 
+// Start fetches messages and spreads them over the workers. Messages of the
+// same partition (or the same key) always go to the same worker, so they are
+// processed in order. In batch mode messages are handled by a single loop instead.
 func (c *Consumer) Start(ctx context.Context) error {
-	defer c.reader.Close()
-	c.logger.Info("Starting Kafka consumer")
+	defer c.Close()
+	if c.batchSize > 0 {
+		return c.startBatch(ctx)
+	}
+
+	c.logger.Infof("Starting Kafka consumer with %d workers", c.concurrency)
+
+	var wg sync.WaitGroup
+	queues := make([]chan kafka.Message, c.concurrency)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, workerQueueSize)
+		wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer wg.Done()
+			c.work(ctx, queue)
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
 
 	for {
 		select {
@@ -45,51 +134,338 @@ func (c *Consumer) Start(ctx context.Context) error {
 			c.logger.Info("Kafka consumer stopped")
 			return ctx.Err()
 		default:
-			m, err := c.reader.ReadMessage(ctx)
+			// FetchMessage doesn't commit on its own, unlike ReadMessage
+			m, err := c.reader.FetchMessage(ctx)
 			if err != nil {
+				if ctx.Err() != nil {
+					c.logger.Info("Kafka consumer stopped")
+					return ctx.Err()
+				}
 				c.logger.Errorf("Error reading message: %v", err)
 				continue
 			}
 
 			c.logger.Infof("Received message from Kafka: topic=%s, partition=%d, offset=%d",
 				m.Topic, m.Partition, m.Offset)
+			observeFetched(m)
 
-			if err := c.processMessage(ctx, m); err != nil {
-				c.logger.Errorf("Error processing message: %v", err)
-				// Never commit offset on error, so the message will be processed again
-				continue
+			c.offsets.track(m)
+			select {
+			case queues[c.workerFor(m)] <- m:
+			case <-ctx.Done():
+				c.logger.Info("Kafka consumer stopped")
+				return ctx.Err()
 			}
+		}
+	}
+}
 
-			// Commit offset only after successful processing
-			if err := c.reader.CommitMessages(ctx, m); err != nil {
-				c.logger.Errorf("Error committing message: %v", err)
-			} else {
-				c.logger.Infof("Message committed: topic=%s, partition=%d, offset=%d",
-					m.Topic, m.Partition, m.Offset)
-			}
+func (c *Consumer) work(ctx context.Context, queue <-chan kafka.Message) {
+	for m := range queue {
+		// Drain the queue on shutdown, the messages will be redelivered after restart
+		if ctx.Err() != nil {
+			continue
 		}
+
+		if err := c.handleMessage(ctx, m); err != nil {
+			c.logger.Errorf("Error handling message: %v", err)
+			// Only reached on shutdown, the message will be redelivered after restart
+			continue
+		}
+
+		c.commit(ctx, m)
+	}
+}
+
+// commit commits the message once every earlier message of its partition
+// has been handled too
+func (c *Consumer) commit(ctx context.Context, m kafka.Message) {
+	// Serialized so that commits of a partition never go backwards
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+
+	last, handled := c.offsets.complete(m)
+	if handled == 0 {
+		c.logger.Debugf("Commit deferred until earlier messages are handled: partition=%d, offset=%d",
+			m.Partition, m.Offset)
+		return
+	}
+
+	// Commit offset only after the message was processed or dead-lettered
+	if err := c.reader.CommitMessages(ctx, last); err != nil {
+		c.logger.Errorf("Error committing message: %v", err)
+	} else {
+		metrics.MessagesCommitted.WithLabelValues(last.Topic, metrics.Partition(last.Partition)).Add(float64(handled))
+		c.logger.Infof("Message committed: topic=%s, partition=%d, offset=%d",
+			last.Topic, last.Partition, last.Offset)
 	}
 }
 
-func (c *Consumer) processMessage(ctx context.Context, m kafka.Message) error {
+func (c *Consumer) workerFor(m kafka.Message) int {
+	if c.byKey && len(m.Key) > 0 {
+		h := fnv.New32a()
+		h.Write(m.Key)
+		return int(h.Sum32() % uint32(c.concurrency))
+	}
+	return m.Partition % c.concurrency
+}
+
+// handleMessage processes the message, retrying transient failures with
+// exponential backoff, and dead-letters it once it fails permanently or
+// the retry budget is exhausted
+func (c *Consumer) handleMessage(ctx context.Context, m kafka.Message) error {
+	start := time.Now()
+
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		if err = c.processMessage(ctx, m); err == nil {
+			return nil
+		}
+		// Interrupted by shutdown, leave the message for the next run
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		metrics.MessagesFailed.WithLabelValues(m.Topic, metrics.Partition(m.Partition), errorClass(err)).Inc()
+
+		if service.IsPermanent(err) {
+			c.logger.Warnf("Permanent error, not retrying: partition=%d, offset=%d: %v",
+				m.Partition, m.Offset, err)
+			break
+		}
+		if attempt >= c.maxRetries {
+			c.logger.Warnf("Retry budget exhausted after %d attempts: partition=%d, offset=%d: %v",
+				attempt, m.Partition, m.Offset, err)
+			break
+		}
+
+		delay := c.backoff.delay(attempt)
+		if c.maxElapsed > 0 && time.Since(start)+delay > c.maxElapsed {
+			c.logger.Warnf("Retry time budget exhausted after %d attempts: partition=%d, offset=%d: %v",
+				attempt, m.Partition, m.Offset, err)
+			break
+		}
+
+		c.logger.Warnf("Attempt %d/%d failed, retrying in %s: partition=%d, offset=%d: %v",
+			attempt, c.maxRetries, delay, m.Partition, m.Offset, err)
+		if err := c.wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	// Keep trying until the message is stored somewhere, otherwise it would be lost
+	for retry := 1; ; retry++ {
+		dlqErr := c.deadLetter(ctx, m, err, attempt)
+		if dlqErr == nil {
+			metrics.MessagesDeadLettered.WithLabelValues(m.Topic, metrics.Partition(m.Partition)).Inc()
+			return nil
+		}
+		c.logger.Errorf("Failed to dead-letter message: partition=%d, offset=%d: %v",
+			m.Partition, m.Offset, dlqErr)
+
+		if err := c.wait(ctx, c.backoff.delay(retry)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Consumer) processMessage(ctx context.Context, m kafka.Message) (err error) {
+	ctx, span := startMessageSpan(ctx, "Consumer.processMessage", m)
+	defer func() { tracing.End(span, err) }()
+	ctx = models.WithOrigin(ctx, messageOrigin(m))
+
+	if c.isStatusChange(m) {
+		return c.processStatusChange(ctx, m, span)
+	}
+
 	var order models.Order
 	if err := json.Unmarshal(m.Value, &order); err != nil {
 		c.logger.Errorf("Failed to unmarshal order: %v", err)
-		return fmt.Errorf("failed to unmarshal order: %w", err)
+		// A payload that can't be decoded won't decode on retry either
+		return &service.PermanentError{Err: fmt.Errorf("failed to unmarshal order: %w", err)}
 	}
 
 	c.logger.Infof("Processing order: %s", order.OrderUID)
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
 
 	// Validation + Save to DB + cache
-	if err := c.svc.CreateOrder(ctx, &order); err != nil {
+	outcome, err := c.svc.CreateOrder(ctx, &order)
+	if err != nil {
 		c.logger.Errorf("Failed to create order: %v", err)
 		return fmt.Errorf("failed to create order: %w", err)
 	}
 
-	c.logger.Infof("Successfully processed order: %s", order.OrderUID)
+	c.logger.Infof("Successfully processed order (%s): %s", outcome, order.OrderUID)
+	return nil
+}
+
+// actorHeader names who produced the message, for the audit trail
+const actorHeader = "actor"
+
+// messageOrigin is the origin of the changes made by the message
+func messageOrigin(m kafka.Message) models.Origin {
+	headers := m.Headers
+	actor := headerCarrier{headers: &headers}.Get(actorHeader)
+	if actor == "" {
+		actor = "kafka"
+	}
+	return models.Origin{
+		Source: models.EventSource{Kafka: &models.KafkaSource{
+			Topic:     m.Topic,
+			Partition: m.Partition,
+			Offset:    m.Offset,
+		}},
+		Actor: actor,
+	}
+}
+
+func (c *Consumer) isStatusChange(m kafka.Message) bool {
+	return c.statusTopic != "" && m.Topic == c.statusTopic
+}
+
+func (c *Consumer) processStatusChange(ctx context.Context, m kafka.Message, span trace.Span) error {
+	var change models.StatusChange
+	if err := json.Unmarshal(m.Value, &change); err != nil {
+		c.logger.Errorf("Failed to unmarshal status change: %v", err)
+		return &service.PermanentError{Err: fmt.Errorf("failed to unmarshal status change: %w", err)}
+	}
+	// The previous status is decided by the service, not by the producer
+	change.From = ""
+
+	c.logger.Infof("Processing status change: %s -> %s", change.OrderUID, change.To)
+	span.SetAttributes(
+		attribute.String("order.uid", change.OrderUID),
+		attribute.String("order.status", string(change.To)),
+	)
+
+	if err := c.svc.ChangeOrderStatus(ctx, &change); err != nil {
+		c.logger.Errorf("Failed to change order status: %v", err)
+		return fmt.Errorf("failed to change order status: %w", err)
+	}
+
+	c.logger.Infof("Successfully processed status change: %s -> %s", change.OrderUID, change.To)
 	return nil
 }
 
+// deadLetter publishes the message to the DLQ topic and records it in the
+// quarantine table. It succeeds if at least one of them accepted the message.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, cause error, attempts int) error {
+	headers := make(map[string]string, len(m.Headers))
+	for _, h := range m.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	msg := &models.QuarantinedMessage{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Payload:   m.Value,
+		Headers:   headers,
+		Error:     cause.Error(),
+		Attempts:  attempts,
+	}
+	var validationErr *models.ValidationError
+	if errors.As(cause, &validationErr) {
+		msg.ValidationErrors = validationErr.Fields
+	}
+
+	dlqErr := c.publishDeadLetter(ctx, m, msg, cause)
+	if dlqErr != nil {
+		c.logger.Errorf("Failed to publish message to DLQ: %v", dlqErr)
+	} else {
+		c.logger.Warnf("Message published to DLQ: topic=%s, partition=%d, offset=%d",
+			m.Topic, m.Partition, m.Offset)
+	}
+
+	quarantineErr := c.svc.QuarantineMessage(ctx, msg)
+	if quarantineErr != nil {
+		c.logger.Errorf("Failed to quarantine message: %v", quarantineErr)
+	}
+
+	if dlqErr != nil && quarantineErr != nil {
+		return fmt.Errorf("dlq: %v, quarantine: %w", dlqErr, quarantineErr)
+	}
+	return nil
+}
+
+func (c *Consumer) publishDeadLetter(ctx context.Context, m kafka.Message, msg *models.QuarantinedMessage, cause error) error {
+	headers := make([]kafka.Header, 0, len(m.Headers)+7)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "x-original-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "x-original-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "x-original-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: "x-error", Value: []byte(msg.Error)},
+		kafka.Header{Key: "x-error-class", Value: []byte(errorClass(cause))},
+		kafka.Header{Key: "x-attempts", Value: []byte(strconv.Itoa(msg.Attempts))},
+	)
+	if len(msg.ValidationErrors) > 0 {
+		if b, err := json.Marshal(msg.ValidationErrors); err == nil {
+			headers = append(headers, kafka.Header{Key: "x-validation-errors", Value: b})
+		}
+	}
+	// Consumers of the DLQ continue the trace of the failed processing
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &headers})
+
+	return c.dlqWriter.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+}
+
+func errorClass(err error) string {
+	if service.IsPermanent(err) {
+		return "permanent"
+	}
+	return "transient"
+}
+
+// observeFetched counts the message and updates the lag of its partition
+func observeFetched(m kafka.Message) {
+	partition := metrics.Partition(m.Partition)
+	metrics.MessagesConsumed.WithLabelValues(m.Topic, partition).Inc()
+	metrics.ConsumerLag.WithLabelValues(m.Topic, partition).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+}
+
+func (c *Consumer) wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Ping checks that a broker is reachable and knows the consumed topics
+func (c *Consumer) Ping(ctx context.Context) error {
+	var lastErr error
+	for _, broker := range c.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		defer conn.Close()
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		topics := []string{c.topic}
+		if c.statusTopic != "" {
+			topics = append(topics, c.statusTopic)
+		}
+		_, err = conn.ReadPartitions(topics...)
+		return err
+	}
+	return lastErr
+}
+
 func (c *Consumer) Close() error {
+	if err := c.dlqWriter.Close(); err != nil {
+		c.logger.Errorf("Error closing DLQ writer: %v", err)
+	}
 	return c.reader.Close()
 }
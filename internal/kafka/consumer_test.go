@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"L0/internal/logger"
+	"L0/internal/service"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestProcessMessageDecodeErrorsArePermanent(t *testing.T) {
+	// Messages that fail to decode never reach the service
+	c := &Consumer{statusTopic: "order-status", logger: logger.NewLogger()}
+
+	tests := []struct {
+		name  string
+		topic string
+		value string
+	}{
+		{"malformed json", "orders", `{"order_uid":`},
+		{"wrong type", "orders", `{"order_uid":1}`},
+		{"date only timestamp", "orders", `{"order_uid":"a","date_created":"2021-11-26"}`},
+		{"fractional unix timestamp", "orders", `{"order_uid":"a","payment":{"payment_dt":1637907739.5}}`},
+		{"amount not a number", "orders", `{"order_uid":"a","payment":{"amount":"abc"}}`},
+		{"amount with five decimals", "orders", `{"order_uid":"a","payment":{"amount":84.99999}}`},
+		{"amount out of range", "orders", `{"order_uid":"a","items":[{"price":1e13}]}`},
+		{"status change timestamp", "order-status", `{"order_uid":"a","status":"paid","occurred_at":"yesterday"}`},
+		{"malformed status change", "order-status", `not json`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.processMessage(context.Background(), kafka.Message{Topic: tt.topic, Value: []byte(tt.value)})
+			if err == nil {
+				t.Fatal("processMessage() succeeded")
+			}
+			if !service.IsPermanent(err) {
+				t.Errorf("processMessage() = %v, want a permanent error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker remembers in-flight messages per partition so that a commit
+// never moves past a message that is still being processed
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+// topicPartition identifies a partition among the consumed topics
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+func partitionOf(m kafka.Message) topicPartition {
+	return topicPartition{topic: m.Topic, partition: m.Partition}
+}
+
+type partitionOffsets struct {
+	pending []int64 // offsets in fetch order
+	done    map[int64]kafka.Message
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+// track registers a fetched message. Offsets must be tracked in fetch order.
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partitionOf(m)]
+	// A rewind means the partition was reassigned and is consumed again from
+	// the last committed offset, so the old in-flight state is no longer valid
+	if !ok || (len(p.pending) > 0 && m.Offset <= p.pending[len(p.pending)-1]) {
+		p = &partitionOffsets{done: make(map[int64]kafka.Message)}
+		t.partitions[partitionOf(m)] = p
+	}
+	p.pending = append(p.pending, m.Offset)
+}
+
+// complete marks the message as handled and returns the last message of the
+// handled prefix of its partition, which is safe to commit, along with the
+// prefix length. The length is 0 while an earlier message of the partition
+// is still in flight.
+func (t *offsetTracker) complete(m kafka.Message) (kafka.Message, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partitionOf(m)]
+	// Messages fetched before a rewind are not tracked anymore
+	if !ok || len(p.pending) == 0 || m.Offset < p.pending[0] {
+		return kafka.Message{}, 0
+	}
+	p.done[m.Offset] = m
+
+	var last kafka.Message
+	handled := 0
+	for len(p.pending) > 0 {
+		done, ok := p.done[p.pending[0]]
+		if !ok {
+			break
+		}
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+		last = done
+		handled++
+	}
+
+	return last, handled
+}
@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func msg(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: "orders", Partition: partition, Offset: offset}
+}
+
+func TestOffsetTrackerCompleteInOrder(t *testing.T) {
+	tr := newOffsetTracker()
+	for offset := int64(10); offset < 13; offset++ {
+		tr.track(msg(0, offset))
+	}
+
+	for offset := int64(10); offset < 13; offset++ {
+		last, n := tr.complete(msg(0, offset))
+		if n != 1 || last.Offset != offset {
+			t.Fatalf("complete(%d) = %d, %d; want %d, 1", offset, last.Offset, n, offset)
+		}
+	}
+}
+
+func TestOffsetTrackerCompleteOutOfOrder(t *testing.T) {
+	tr := newOffsetTracker()
+	for offset := int64(0); offset < 4; offset++ {
+		tr.track(msg(0, offset))
+	}
+
+	// Later messages wait for the earliest one
+	for _, offset := range []int64{2, 1, 3} {
+		if _, n := tr.complete(msg(0, offset)); n != 0 {
+			t.Fatalf("complete(%d) handled %d messages while offset 0 is in flight", offset, n)
+		}
+	}
+
+	last, n := tr.complete(msg(0, 0))
+	if n != 4 || last.Offset != 3 {
+		t.Fatalf("complete(0) = %d, %d; want 3, 4", last.Offset, n)
+	}
+}
+
+func TestOffsetTrackerPartitionsAreIndependent(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msg(0, 5))
+	tr.track(msg(1, 7))
+	tr.track(kafka.Message{Topic: "order-status", Partition: 0, Offset: 1})
+
+	if last, n := tr.complete(msg(1, 7)); n != 1 || last.Offset != 7 {
+		t.Fatalf("complete on partition 1 = %d, %d; want 7, 1", last.Offset, n)
+	}
+	// Same partition number of another topic is a different partition
+	if last, n := tr.complete(kafka.Message{Topic: "order-status", Partition: 0, Offset: 1}); n != 1 || last.Offset != 1 {
+		t.Fatalf("complete on order-status = %d, %d; want 1, 1", last.Offset, n)
+	}
+	if last, n := tr.complete(msg(0, 5)); n != 1 || last.Offset != 5 {
+		t.Fatalf("complete on partition 0 = %d, %d; want 5, 1", last.Offset, n)
+	}
+}
+
+func TestOffsetTrackerRewind(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msg(0, 10))
+	tr.track(msg(0, 11))
+	tr.track(msg(0, 12))
+
+	// After a rebalance the partition is consumed again from offset 11
+	tr.track(msg(0, 11))
+	tr.track(msg(0, 12))
+
+	// Completion of a message fetched before the rewind doesn't commit anything
+	if _, n := tr.complete(msg(0, 10)); n != 0 {
+		t.Fatalf("stale message before the rewind handled %d messages", n)
+	}
+
+	if _, n := tr.complete(msg(0, 12)); n != 0 {
+		t.Fatalf("complete(12) handled %d messages while 11 is in flight", n)
+	}
+	last, n := tr.complete(msg(0, 11))
+	if n != 2 || last.Offset != 12 {
+		t.Fatalf("complete(11) = %d, %d; want 12, 2", last.Offset, n)
+	}
+}
+
+func TestOffsetTrackerUntrackedMessage(t *testing.T) {
+	tr := newOffsetTracker()
+	if _, n := tr.complete(msg(3, 1)); n != 0 {
+		t.Fatalf("untracked message handled %d messages", n)
+	}
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+
+	"L0/internal/config"
+	"L0/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// eventTypeHeader tells consumers of the outbox topic what the message is
+const eventTypeHeader = "event-type"
+
+// Publisher publishes outbox messages to the outbox topic
+type Publisher struct {
+	writer *kafka.Writer
+}
+
+func NewPublisher(cfg *config.Config) *Publisher {
+	return &Publisher{
+		writer: &kafka.Writer{
+			Addr:  kafka.TCP(cfg.Kafka.Brokers...),
+			Topic: cfg.Outbox.Topic,
+			// Events of an order share the key and so the partition
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+// Publish writes the messages synchronously, the error covers all of them
+func (p *Publisher) Publish(ctx context.Context, msgs []models.OutboxMessage) error {
+	messages := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		headers := make([]kafka.Header, 0, len(msg.Headers)+1)
+		headers = append(headers, kafka.Header{Key: eventTypeHeader, Value: []byte(msg.EventType)})
+		for key, value := range msg.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		messages[i] = kafka.Message{
+			Key:     []byte(msg.Key),
+			Value:   msg.Payload,
+			Headers: headers,
+		}
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
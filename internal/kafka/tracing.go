@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"L0/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("kafka")
+
+// startMessageSpan starts a consumer span that continues the trace of the
+// producer found in the message headers
+func startMessageSpan(ctx context.Context, name string, m kafka.Message) (context.Context, trace.Span) {
+	ctx = messageContext(ctx, m)
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(m)...),
+	)
+}
+
+// messageContext returns ctx with the trace context extracted from the message headers
+func messageContext(ctx context.Context, m kafka.Message) context.Context {
+	headers := m.Headers
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &headers})
+}
+
+func messageAttributes(m kafka.Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", m.Topic),
+		attribute.String("messaging.destination.partition.id", strconv.Itoa(m.Partition)),
+		attribute.Int64("messaging.kafka.offset", m.Offset),
+		attribute.String("messaging.kafka.message.key", string(m.Key)),
+	}
+}
+
+// headerCarrier adapts Kafka message headers to propagation.TextMapCarrier,
+// so that W3C trace context can be read from and written to messages
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "l0"
+
+var (
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Messages fetched from Kafka.",
+	}, []string{"topic", "partition"})
+
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Failed processing attempts by error class (permanent or transient).",
+	}, []string{"topic", "partition", "class"})
+
+	MessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_dead_lettered_total",
+		Help:      "Messages moved to the DLQ topic or the quarantine table.",
+	}, []string{"topic", "partition"})
+
+	MessagesCommitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_committed_total",
+		Help:      "Messages whose offsets were committed.",
+	}, []string{"topic", "partition"})
+
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages between the last fetched offset and the high watermark.",
+	}, []string{"topic", "partition"})
+
+	CreateOrderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "create_order_duration_seconds",
+		Help:      "Order creation latency by save outcome, orders of a batch are observed with the latency of the batch.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	RuleViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "rule_violations_total",
+		Help:      "Business rule violations of incoming orders by rule and severity.",
+	}, []string{"rule", "severity"})
+
+	GetOrderLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "get_order_lookups_total",
+		Help:      "OrderService.GetOrderByID cache misses by how they were served: database or coalesced with a concurrent lookup.",
+	}, []string{"source"})
+
+	StatusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "status_changes_total",
+		Help:      "Order status-change events by target status, invalid if it failed validation, and outcome: applied, duplicate or rejected.",
+	}, []string{"status", "outcome"})
+
+	OutboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "published_total",
+		Help:      "Outbox messages published downstream.",
+	})
+
+	OutboxRelayFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "relay_failures_total",
+		Help:      "Failed relay runs, their messages are published again later.",
+	})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "query_duration_seconds",
+		Help:      "Repository operation latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache operations by result: hit, miss, ok or error.",
+	}, []string{"cache", "operation", "result"})
+
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Entries removed from an in-process cache by reason: size or expired.",
+	}, []string{"cache", "reason"})
+
+	CacheEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "entries",
+		Help:      "Entries held by an in-process cache.",
+	}, []string{"cache"})
+
+	CacheBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "bytes",
+		Help:      "Encoded size of the entries held by an in-process cache.",
+	}, []string{"cache"})
+
+	CacheWarmupOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "warmup_orders",
+		Help:      "Orders loaded into the cache by the startup warm-up so far.",
+	})
+
+	CacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "invalidations_total",
+		Help:      "Keys invalidated between instances by direction: sent or received.",
+	}, []string{"direction"})
+
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Status turns an error into a label value
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Partition turns a partition number into a label value
+func Partition(partition int) string {
+	return strconv.Itoa(partition)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// EventType tells what happened to an order
+type EventType string
+
+const (
+	EventCreated       EventType = "created"
+	EventUpdated       EventType = "updated"
+	EventStatusChanged EventType = "status_changed"
+	// EventRedelivered is an identical copy of the order received again
+	EventRedelivered EventType = "redelivered"
+	// EventRejected is a different version of the order that the conflict
+	// policy didn't let replace the stored one
+	EventRejected EventType = "rejected"
+)
+
+// OrderEvent is an entry of the audit trail of an order. Previous and New
+// are the order JSON before and after the event, status changes store
+// {"status": ...} instead. Redelivered and rejected orders keep the stored
+// order in Previous and the received one in New.
+type OrderEvent struct {
+	ID         int64           `db:"id" json:"id"`
+	OrderUID   string          `db:"order_uid" json:"order_uid"`
+	Type       EventType       `db:"event_type" json:"type"`
+	Previous   json.RawMessage `db:"previous_data" json:"previous,omitempty"`
+	New        json.RawMessage `db:"new_data" json:"new,omitempty"`
+	Source     EventSource     `db:"source" json:"source"`
+	Actor      string          `db:"actor" json:"actor"`
+	OccurredAt Timestamp       `db:"occurred_at" json:"occurred_at"`
+	Changes    []JSONChange    `db:"-" json:"changes,omitempty"` // diff of Previous and New
+}
+
+// EventSource describes where a change came from
+type EventSource struct {
+	Kafka *KafkaSource `json:"kafka,omitempty"`
+	HTTP  *HTTPSource  `json:"http,omitempty"`
+}
+
+type KafkaSource struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+type HTTPSource struct {
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	ClientIP       string `json:"client_ip"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// Value stores the source in a JSONB column
+func (s EventSource) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *EventSource) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case nil:
+		*s = EventSource{}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into EventSource", src)
+}
+
+// Origin is the source and the actor of the changes made within a context
+type Origin struct {
+	Source EventSource
+	Actor  string
+}
+
+type originKey struct{}
+
+type orderOriginsKey struct{}
+
+// WithOrigin attaches the origin of the changes to the context
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// WithOrderOrigins attaches origins of individual orders, e.g. of the
+// messages of a Kafka batch saved at once
+func WithOrderOrigins(ctx context.Context, origins map[*Order]Origin) context.Context {
+	return context.WithValue(ctx, orderOriginsKey{}, origins)
+}
+
+// OriginFrom returns the origin attached with WithOrigin, the zero Origin if
+// there is none
+func OriginFrom(ctx context.Context) Origin {
+	origin, _ := ctx.Value(originKey{}).(Origin)
+	return origin
+}
+
+// OrderOrigin returns the origin of the order attached with
+// WithOrderOrigins, or OriginFrom the context
+func OrderOrigin(ctx context.Context, order *Order) Origin {
+	if origins, ok := ctx.Value(orderOriginsKey{}).(map[*Order]Origin); ok {
+		if origin, ok := origins[order]; ok {
+			return origin
+		}
+	}
+	return OriginFrom(ctx)
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// JSONChange is a difference between two JSON documents at a path like
+// items[0].price
+type JSONChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"` // added, removed or changed
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// DiffJSON returns the changes turning document a into b. An empty document
+// counts as missing, so diffing against it lists the other one as added.
+func DiffJSON(a, b []byte) ([]JSONChange, error) {
+	oldDoc, err := decodeJSON(a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode old document: %w", err)
+	}
+	newDoc, err := decodeJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode new document: %w", err)
+	}
+
+	var changes []JSONChange
+	diffValues("", oldDoc, newDoc, &changes)
+	return changes, nil
+}
+
+func decodeJSON(data []byte) (any, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Numbers are compared as written, float64 would hide changes of money
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func diffValues(path string, a, b any, changes *[]JSONChange) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*changes = append(*changes, JSONChange{Path: rootPath(path), Op: ChangeAdded, New: b})
+		return
+	case b == nil:
+		*changes = append(*changes, JSONChange{Path: rootPath(path), Op: ChangeRemoved, Old: a})
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			diffObjects(path, av, bv, changes)
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			diffArrays(path, av, bv, changes)
+			return
+		}
+	default:
+		if a == b {
+			return
+		}
+	}
+	*changes = append(*changes, JSONChange{Path: rootPath(path), Op: ChangeChanged, Old: a, New: b})
+}
+
+func diffObjects(path string, a, b map[string]any, changes *[]JSONChange) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		field := k
+		if path != "" {
+			field = path + "." + k
+		}
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inA:
+			*changes = append(*changes, JSONChange{Path: field, Op: ChangeAdded, New: bv})
+		case !inB:
+			*changes = append(*changes, JSONChange{Path: field, Op: ChangeRemoved, Old: av})
+		default:
+			diffValues(field, av, bv, changes)
+		}
+	}
+}
+
+// diffArrays compares elements by position
+func diffArrays(path string, a, b []any, changes *[]JSONChange) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		elem := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= len(a):
+			*changes = append(*changes, JSONChange{Path: elem, Op: ChangeAdded, New: b[i]})
+		case i >= len(b):
+			*changes = append(*changes, JSONChange{Path: elem, Op: ChangeRemoved, Old: a[i]})
+		default:
+			diffValues(elem, a[i], b[i], changes)
+		}
+	}
+}
+
+func rootPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
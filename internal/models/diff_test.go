@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string // the changes encoded as JSON
+	}{
+		{
+			name: "equal",
+			a:    `{"order_uid":"a","items":[{"price":1}]}`,
+			b:    `{"items":[{"price":1}],"order_uid":"a"}`,
+			want: `null`,
+		},
+		{
+			name: "changed field",
+			a:    `{"delivery":{"city":"Kiryat Mozkin","zip":"2639809"}}`,
+			b:    `{"delivery":{"city":"Moscow","zip":"2639809"}}`,
+			want: `[{"path":"delivery.city","op":"changed","old":"Kiryat Mozkin","new":"Moscow"}]`,
+		},
+		{
+			name: "added and removed fields in key order",
+			a:    `{"b":1,"c":{"x":true}}`,
+			b:    `{"a":"new","b":1}`,
+			want: `[{"path":"a","op":"added","new":"new"},{"path":"c","op":"removed","old":{"x":true}}]`,
+		},
+		{
+			name: "null counts as missing",
+			a:    `{"a":null,"b":2}`,
+			b:    `{"a":1,"b":null}`,
+			want: `[{"path":"a","op":"added","new":1},{"path":"b","op":"removed","old":2}]`,
+		},
+		{
+			name: "numbers are compared as written",
+			a:    `{"price":84.99,"sale":30}`,
+			b:    `{"price":84.990000000000001,"sale":30.0}`,
+			want: `[{"path":"price","op":"changed","old":84.99,"new":84.990000000000001},` +
+				`{"path":"sale","op":"changed","old":30,"new":30.0}]`,
+		},
+		{
+			name: "type change",
+			a:    `{"items":[1,2]}`,
+			b:    `{"items":{"0":1}}`,
+			want: `[{"path":"items","op":"changed","old":[1,2],"new":{"0":1}}]`,
+		},
+		{
+			name: "array elements by position",
+			a:    `{"items":[{"price":1,"name":"a"},{"price":2,"name":"b"}]}`,
+			b:    `{"items":[{"price":1,"name":"a"},{"price":3,"name":"b"}]}`,
+			want: `[{"path":"items[1].price","op":"changed","old":2,"new":3}]`,
+		},
+		{
+			name: "array element added",
+			a:    `{"items":[{"rid":"a"}]}`,
+			b:    `{"items":[{"rid":"a"},{"rid":"b"}]}`,
+			want: `[{"path":"items[1]","op":"added","new":{"rid":"b"}}]`,
+		},
+		{
+			name: "array element removed",
+			a:    `{"items":["a","b","c"]}`,
+			b:    `{"items":["a"]}`,
+			want: `[{"path":"items[1]","op":"removed","old":"b"},{"path":"items[2]","op":"removed","old":"c"}]`,
+		},
+		{
+			name: "array reordered",
+			a:    `[1,2]`,
+			b:    `[2,1]`,
+			want: `[{"path":"[0]","op":"changed","old":1,"new":2},{"path":"[1]","op":"changed","old":2,"new":1}]`,
+		},
+		{
+			name: "nested arrays",
+			a:    `{"a":[[1],[2]]}`,
+			b:    `{"a":[[1],[2,3]]}`,
+			want: `[{"path":"a[1][1]","op":"added","new":3}]`,
+		},
+		{
+			name: "empty old document",
+			a:    ``,
+			b:    `{"order_uid":"a"}`,
+			want: `[{"path":"$","op":"added","new":{"order_uid":"a"}}]`,
+		},
+		{
+			name: "empty new document",
+			a:    `{"order_uid":"a"}`,
+			b:    " \n",
+			want: `[{"path":"$","op":"removed","old":{"order_uid":"a"}}]`,
+		},
+		{
+			name: "changed root",
+			a:    `"a"`,
+			b:    `"b"`,
+			want: `[{"path":"$","op":"changed","old":"a","new":"b"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := DiffJSON([]byte(tt.a), []byte(tt.b))
+			if err != nil {
+				t.Fatalf("DiffJSON(): %v", err)
+			}
+			got, err := json.Marshal(changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("DiffJSON() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffJSONInvalid(t *testing.T) {
+	if _, err := DiffJSON([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("DiffJSON() of an invalid old document succeeded")
+	}
+	if _, err := DiffJSON([]byte(`{}`), []byte(`[1,`)); err == nil {
+		t.Error("DiffJSON() of an invalid new document succeeded")
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	SortByDateCreated = "date_created"
+	SortByOrderUID    = "order_uid"
+)
+
+// OrderFilter describes a search over orders. Empty fields are not filtered on.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	Provider        string // payment provider
+	Bank            string // payment bank
+	Brand           string // brand of any item
+	NmID            int    // nm_id of any item
+	CreatedFrom     *time.Time
+	CreatedTo       *time.Time
+
+	SortBy string // date_created or order_uid
+	Desc   bool
+	Limit  int
+	Cursor string // opaque, taken from OrderPage.NextCursor
+}
+
+// OrderPage is one page of search results
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
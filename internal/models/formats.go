@@ -0,0 +1,112 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// e164Pattern is a phone number in the E.164 format: + and up to 15 digits
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// maxCallingCodeLength is the length of the longest country calling code
+const maxCallingCodeLength = 3
+
+// validatePhone implements the "phone" tag: an E.164 number with a known
+// country calling code
+func validatePhone(fl validator.FieldLevel) bool {
+	return phoneCountries(fl.Field().String()) != nil
+}
+
+// phoneCountries returns the countries sharing the calling code of an E.164
+// number, or nil if the number is invalid. Calling codes are prefix-free, so
+// at most one prefix of the number matches.
+func phoneCountries(phone string) []country {
+	if !e164Pattern.MatchString(phone) {
+		return nil
+	}
+	digits := phone[1:]
+	for n := 1; n <= maxCallingCodeLength; n++ {
+		if found, ok := countriesByCallingCode[digits[:n]]; ok {
+			return found
+		}
+	}
+	return nil
+}
+
+// validateCurrency implements the "currency" tag: an ISO 4217 code in
+// circulation, in upper case
+func validateCurrency(fl validator.FieldLevel) bool {
+	_, ok := currencyDigits[fl.Field().String()]
+	return ok
+}
+
+// validateLocale implements the "locale" tag: a BCP 47 language tag of an
+// ISO 639-1 language with an optional script and region, e.g. en, ru-RU,
+// sr-Latn-RS or es-419. Tags are case-insensitive.
+func validateLocale(fl validator.FieldLevel) bool {
+	subtags := strings.Split(fl.Field().String(), "-")
+	if !languages[strings.ToLower(subtags[0])] {
+		return false
+	}
+	subtags = subtags[1:]
+	if len(subtags) > 0 && len(subtags[0]) == 4 && isLetters(subtags[0]) {
+		subtags = subtags[1:]
+	}
+	switch {
+	case len(subtags) == 0:
+		return true
+	case len(subtags) > 1:
+		return false
+	}
+	region := subtags[0]
+	if len(region) == 3 && isDigits(region) {
+		return true
+	}
+	_, ok := countries[strings.ToUpper(region)]
+	return len(region) == 2 && ok
+}
+
+// validatePostcode implements the "postcode" tag of Delivery.Zip. The country
+// comes from the calling code of the phone number; when the code is shared,
+// the zip must match the format of any of its countries. Zips of countries
+// without a known format and of invalid phones, which fail on their own,
+// aren't checked.
+func validatePostcode(fl validator.FieldLevel) bool {
+	delivery, ok := fl.Parent().Interface().(Delivery)
+	if !ok {
+		return true
+	}
+	candidates := phoneCountries(delivery.Phone)
+	zip := strings.ToUpper(strings.TrimSpace(fl.Field().String()))
+	checked := false
+	for _, c := range candidates {
+		if c.postal == nil {
+			continue
+		}
+		if c.postal.MatchString(zip) {
+			return true
+		}
+		checked = true
+	}
+	return !checked
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key, so a retry gets the same answer
+type IdempotencyRecord struct {
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"`
+	StatusCode  int       `db:"status_code"` // 0 while the request is in progress
+	Response    []byte    `db:"response"`
+	CreatedAt   time.Time `db:"created_at"`
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// moneyScale is the number of decimal places Money keeps, enough for the
+// minor units of every ISO 4217 currency
+const moneyScale = 4
+
+var (
+	// moneyPattern is the syntax of a JSON number, with a short exponent
+	moneyPattern = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d{1,3})?$`)
+
+	moneyUnit = int64(math.Pow10(moneyScale))
+	// maxMoneyUnits bounds amounts to 10^12, so sums of a few hundred of
+	// them can't overflow
+	maxMoneyUnits = int64(1e12) * moneyUnit
+)
+
+// Money is an exact decimal amount with up to four decimal places. It is
+// encoded as a JSON number and stored as NUMERIC, so amounts round-trip
+// without the rounding errors of float64. The zero value is 0.
+type Money struct {
+	units int64 // the amount multiplied by 10^moneyScale
+}
+
+// ParseMoney parses a decimal such as 317, 84.99 or 1.5e3. Amounts with
+// more than four significant decimal places are rejected, not rounded.
+func ParseMoney(s string) (Money, error) {
+	if !moneyPattern.MatchString(s) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(moneyUnit))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places", s, moneyScale)
+	}
+	units := r.Num()
+	if !units.IsInt64() || abs(units.Int64()) > maxMoneyUnits {
+		return Money{}, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Money{units: units.Int64()}, nil
+}
+
+// MoneyFromFloat converts f, rounding it to four decimal places. It is meant
+// for settings, amounts of orders are parsed exactly.
+func MoneyFromFloat(f float64) Money {
+	return Money{units: int64(math.Round(f * float64(moneyUnit)))}
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{units: m.units + other.units}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{units: m.units - other.units}
+}
+
+func (m Money) Abs() Money {
+	return Money{units: abs(m.units)}
+}
+
+// Cmp returns -1, 0 or +1 if m is less than, equal to or greater than other
+func (m Money) Cmp(other Money) int {
+	switch {
+	case m.units < other.units:
+		return -1
+	case m.units > other.units:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) IsZero() bool {
+	return m.units == 0
+}
+
+// Float64 returns the nearest float64, e.g. for metrics
+func (m Money) Float64() float64 {
+	return float64(m.units) / float64(moneyUnit)
+}
+
+// Round rounds m to the minor units of the currency, half away from zero.
+// Amounts in unknown currencies are returned as is.
+func (m Money) Round(currency string) Money {
+	return m.mulRound(big.NewRat(1, 1), currency)
+}
+
+// Percent returns percent of m rounded to the minor units of the currency,
+// e.g. the price of an item after a sale
+func (m Money) Percent(percent float64, currency string) Money {
+	factor := new(big.Rat)
+	if factor.SetFloat64(percent) == nil {
+		return Money{}
+	}
+	return m.mulRound(factor.Quo(factor, big.NewRat(100, 1)), currency)
+}
+
+// HasCurrencyPrecision reports whether m has no more decimal places than
+// the minor units of the currency allow, e.g. 1.5 JPY doesn't
+func (m Money) HasCurrencyPrecision(currency string) bool {
+	return m.Round(currency) == m
+}
+
+// mulRound multiplies m by factor and rounds the result once, to the minor
+// units of the currency or to the precision of Money if it is unknown
+func (m Money) mulRound(factor *big.Rat, currency string) Money {
+	digits, ok := CurrencyDigits(currency)
+	if !ok || digits > moneyScale {
+		digits = moneyScale
+	}
+	step := int64(math.Pow10(moneyScale - digits))
+
+	r := new(big.Rat).SetInt64(m.units)
+	r.Mul(r, factor)
+	r.Quo(r, new(big.Rat).SetInt64(step))
+
+	// Round half away from zero: add or subtract 1/2 and truncate
+	half := big.NewRat(1, 2)
+	if r.Sign() < 0 {
+		half.Neg(half)
+	}
+	r.Add(r, half)
+	rounded := new(big.Int).Quo(r.Num(), r.Denom())
+	return Money{units: rounded.Int64() * step}
+}
+
+// String formats m without trailing zeros, e.g. 84.99 or 317
+func (m Money) String() string {
+	s := strconv.FormatInt(abs(m.units/moneyUnit), 10)
+	if frac := abs(m.units % moneyUnit); frac != 0 {
+		digits := fmt.Sprintf("%0*d", moneyScale, frac)
+		s += "." + strings.TrimRight(digits, "0")
+	}
+	if m.units < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one. null leaves m
+// unchanged, like it does for the builtin types.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores m in a NUMERIC column
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*m, err = ParseMoney(string(v))
+	case string:
+		*m, err = ParseMoney(v)
+	case int64:
+		*m, err = ParseMoney(strconv.FormatInt(v, 10))
+	case nil:
+		return errors.New("cannot scan NULL into Money")
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return err
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
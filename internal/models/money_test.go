@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "317", want: "317"},
+		{in: "84.99", want: "84.99"},
+		{in: "0.0001", want: "0.0001"},
+		{in: "-12.5", want: "-12.5"},
+		{in: "1.5e3", want: "1500"},
+		{in: "1E-4", want: "0.0001"},
+		{in: "2.50000", want: "2.5"},
+		{in: "1000000000000", want: "1000000000000"},
+		{in: "0.00001", wantErr: true},
+		{in: "84.99999", wantErr: true},
+		{in: "1e-5", wantErr: true},
+		{in: "1000000000000.0001", wantErr: true},
+		{in: "1e13", wantErr: true},
+		{in: "1e1000", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "1.", wantErr: true},
+		{in: "+1", wantErr: true},
+		{in: "0x10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `84.99`, want: "84.99"},
+		{in: `"84.99"`, want: "84.99"},
+		{in: `"1.5e3"`, want: "1500"},
+		{in: `null`, want: "7"},
+		{in: `"84.99999"`, wantErr: true},
+		{in: `""`, wantErr: true},
+		{in: `"abc"`, wantErr: true},
+		{in: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		// null leaves the previous value
+		m := MoneyFromFloat(7)
+		err := json.Unmarshal([]byte(tt.in), &m)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want an error", tt.in, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if m.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, m, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    string
+		wantErr bool
+	}{
+		{src: []byte("84.9900"), want: "84.99"},
+		{src: []byte("317"), want: "317"},
+		{src: "0.0001", want: "0.0001"},
+		{src: int64(1500), want: "1500"},
+		{src: []byte("84.99999"), wantErr: true},
+		{src: nil, wantErr: true},
+		{src: 84.99, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %s, want an error", tt.src, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if m.String() != tt.want {
+			t.Errorf("Scan(%#v) = %s, want %s", tt.src, m, tt.want)
+		}
+	}
+}
+
+func TestMoneyValueRoundTrip(t *testing.T) {
+	for _, s := range []string{"0", "84.99", "-0.0001", "1000000000000"} {
+		m, err := ParseMoney(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := m.Value()
+		if err != nil {
+			t.Fatalf("Value() of %s: %v", s, err)
+		}
+		if v != s {
+			t.Errorf("Value() = %#v, want %q", v, s)
+		}
+
+		var scanned Money
+		if err := scanned.Scan([]byte(v.(string))); err != nil || scanned != m {
+			t.Errorf("Scan(Value()) of %s = %s, %v", s, scanned, err)
+		}
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount   string
+		percent  float64
+		currency string
+		want     string
+	}{
+		{"453", 70, "USD", "317.1"},
+		{"453", 70, "JPY", "317"},
+		{"0.05", 50, "USD", "0.03"},
+		{"-0.05", 50, "USD", "-0.03"},
+		{"10", 100, "BHD", "10"},
+		{"1.2345", 10, "XXX", "0.1235"},
+		{"999.99", 0, "USD", "0"},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Percent(tt.percent, tt.currency); got.String() != tt.want {
+			t.Errorf("%s.Percent(%v, %s) = %s, want %s", tt.amount, tt.percent, tt.currency, got, tt.want)
+		}
+	}
+}
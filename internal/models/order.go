@@ -1,65 +1,116 @@
 package models
 
 import (
+	"errors"
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
 type Order struct {
-	OrderUID          string   `db:"order_uid" validate:"required,alphanum" json:"order_uid"`
-	TrackNumber       string   `db:"track_number" validate:"required" json:"track_number"`
-	Entry             string   `db:"entry" validate:"required" json:"entry"`
-	Delivery          Delivery `db:"delivery" validate:"required" json:"delivery"`
-	Payment           Payment  `db:"payment" validate:"required" json:"payment"`
-	Items             []Item   `db:"items" validate:"required" json:"items"`
-	Locale            string   `db:"locale" validate:"required" json:"locale"`
-	InternalSignature string   `db:"internal_signature" json:"internal_signature"`
-	CustomerID        string   `db:"customer_id" validate:"required" json:"customer_id"`
-	DeliveryService   string   `db:"delivery_service" json:"delivery_service"`
-	ShardKey          string   `db:"shardkey" json:"shardkey"`
-	SmID              int      `db:"sm_id" json:"sm_id"`
-	DateCreated       string   `db:"date_created" validate:"required,datetime=2006-01-02T15:04:05Z" json:"date_created"`
-	OofShard          string   `db:"oof_shard" json:"oof_shard"`
+	OrderUID          string    `db:"order_uid" validate:"required,alphanum" json:"order_uid"`
+	TrackNumber       string    `db:"track_number" validate:"required" json:"track_number"`
+	Entry             string    `db:"entry" validate:"required" json:"entry"`
+	Delivery          Delivery  `db:"delivery" validate:"required" json:"delivery"`
+	Payment           Payment   `db:"payment" validate:"required" json:"payment"`
+	Items             []Item    `db:"items" validate:"required,min=1,dive" json:"items"`
+	Locale            string    `db:"locale" validate:"required,locale" json:"locale"`
+	InternalSignature string    `db:"internal_signature" json:"internal_signature"`
+	CustomerID        string    `db:"customer_id" validate:"required" json:"customer_id"`
+	DeliveryService   string    `db:"delivery_service" json:"delivery_service"`
+	ShardKey          string    `db:"shardkey" json:"shardkey"`
+	SmID              int       `db:"sm_id" json:"sm_id"`
+	DateCreated       Timestamp `db:"date_created" validate:"required" json:"date_created"`
+	OofShard          string    `db:"oof_shard" json:"oof_shard"`
 }
 
 type Delivery struct {
-	Name    string `json:"name" validate:"required"`
-	Phone   string `json:"phone" validate:"required"`
-	Zip     string `json:"zip" validate:"required"`
-	City    string `json:"city" validate:"required"`
-	Address string `json:"address" validate:"required"`
-	Region  string `json:"region" validate:"required"`
-	Email   string `json:"email" validate:"required,email"`
+	Name    string `db:"name" json:"name" validate:"required"`
+	Phone   string `db:"phone" json:"phone" validate:"required,phone"`
+	Zip     string `db:"zip" json:"zip" validate:"required,postcode"`
+	City    string `db:"city" json:"city" validate:"required"`
+	Address string `db:"address" json:"address" validate:"required"`
+	Region  string `db:"region" json:"region" validate:"required"`
+	Email   string `db:"email" json:"email" validate:"required,email"`
 }
 
+// Numeric fields where zero is a valid value (free delivery, no sale) use the
+// "present" tag instead of "required", which rejects zeros
 type Payment struct {
-	Transaction  string  `json:"transaction" validate:"required"`
-	RequestID    string  `json:"request_id"`
-	Currency     string  `json:"currency" validate:"required"`
-	Provider     string  `json:"provider" validate:"required"`
-	Amount       float64 `json:"amount" validate:"required"`
-	PaymentDt    int64   `json:"payment_dt" validate:"required"`
-	Bank         string  `json:"bank" validate:"required"`
-	DeliveryCost float64 `json:"delivery_cost" validate:"required"`
-	GoodsTotal   float64 `json:"goods_total" validate:"required"`
-	CustomFee    float64 `json:"custom_fee"`
+	Transaction  string    `db:"transaction" json:"transaction" validate:"required"`
+	RequestID    string    `db:"request_id" json:"request_id"`
+	Currency     string    `db:"currency" json:"currency" validate:"required,currency"`
+	Provider     string    `db:"provider" json:"provider" validate:"required"`
+	Amount       Money     `db:"amount" json:"amount" validate:"present,gte=0"`
+	PaymentDt    Timestamp `db:"payment_dt" json:"payment_dt" validate:"required,gt=0"`
+	Bank         string    `db:"bank" json:"bank" validate:"required"`
+	DeliveryCost Money     `db:"delivery_cost" json:"delivery_cost" validate:"present,gte=0"`
+	GoodsTotal   Money     `db:"goods_total" json:"goods_total" validate:"present,gte=0"`
+	CustomFee    Money     `db:"custom_fee" json:"custom_fee" validate:"gte=0"`
+
+	present presence
 }
 
 type Item struct {
-	ChrtID      int     `json:"chrt_id" validate:"required"`
-	TrackNumber string  `json:"track_number" validate:"required"`
-	Price       float64 `json:"price" validate:"required"`
-	Rid         string  `json:"rid" validate:"required"`
-	Name        string  `json:"name" validate:"required"`
-	Sale        float64 `json:"sale" validate:"required"`
-	Size        string  `json:"size" validate:"required"`
-	TotalPrice  float64 `json:"total_price" validate:"required"`
-	NmID        int     `json:"nm_id" validate:"required"`
-	Brand       string  `json:"brand" validate:"required"`
-	Status      int     `json:"status" validate:"required"`
+	ChrtID      int     `db:"chrt_id" json:"chrt_id" validate:"required,gt=0"`
+	TrackNumber string  `db:"track_number" json:"track_number" validate:"required"`
+	Price       Money   `db:"price" json:"price" validate:"present,gte=0"`
+	Rid         string  `db:"rid" json:"rid" validate:"required"`
+	Name        string  `db:"name" json:"name" validate:"required"`
+	Sale        float64 `db:"sale" json:"sale" validate:"present,gte=0,lte=100"`
+	Size        string  `db:"size" json:"size" validate:"required"`
+	TotalPrice  Money   `db:"total_price" json:"total_price" validate:"present,gte=0"`
+	NmID        int     `db:"nm_id" json:"nm_id" validate:"required,gt=0"`
+	Brand       string  `db:"brand" json:"brand" validate:"required"`
+	Status      int     `db:"status" json:"status" validate:"present,gte=0"`
+
+	present presence
 }
 
-var validate = validator.New()
+var validate = newValidator()
 
+// newValidator reports fields by their JSON names, e.g. items[2].price, and
+// registers the format checks backed by the embedded reference data
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("present", validatePresent)
+	v.RegisterValidation("phone", validatePhone)
+	v.RegisterValidation("currency", validateCurrency)
+	v.RegisterValidation("locale", validateLocale)
+	v.RegisterValidation("postcode", validatePostcode)
+	// Range tags such as gte=0 compare amounts as numbers
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(Money).Float64()
+	}, Money{})
+	// and timestamps as Unix seconds, zero for an unset one
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		t := field.Interface().(Timestamp)
+		if t.IsZero() {
+			return int64(0)
+		}
+		return t.Unix()
+	}, Timestamp{})
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// ValidateOrder returns a *ValidationError listing every invalid field
 func ValidateOrder(order *Order) error {
-	return validate.Struct(order)
+	return validateStruct(order)
+}
+
+func validateStruct(s any) error {
+	err := validate.Struct(s)
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		return newValidationError(errs)
+	}
+	return err
 }
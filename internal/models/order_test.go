@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ordersTestdata = "../../testdata/orders"
+
+// checkOrder decodes and validates an order the way the service does and
+// returns the fields it was rejected for
+func checkOrder(t *testing.T, path string) (fields []string, decodeErr error) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+
+	var validationErr *ValidationError
+	if err := ValidateOrder(&order); errors.As(err, &validationErr) {
+		for _, fe := range validationErr.Fields {
+			fields = append(fields, fe.Field)
+		}
+	} else if err != nil {
+		t.Fatalf("ValidateOrder(): %v", err)
+	}
+
+	errs, _ := NewRuleEngine(Money{}, DefaultRules()...).Check(&order)
+	for _, fe := range errs {
+		fields = append(fields, fe.Field)
+	}
+	return fields, nil
+}
+
+func testdataFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(ordersTestdata, dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no orders in testdata/orders/%s", dir)
+	}
+	return files
+}
+
+func TestValidOrders(t *testing.T) {
+	for _, path := range testdataFiles(t, "valid") {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			fields, err := checkOrder(t, path)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(fields) > 0 {
+				t.Errorf("rejected for %v", fields)
+			}
+		})
+	}
+}
+
+func TestInvalidOrders(t *testing.T) {
+	// The field every invalid order must be rejected for, by its file name.
+	// Orders marked with decode must already fail to decode.
+	tests := map[string]struct {
+		field  string
+		decode bool
+	}{
+		"date_only.json":              {decode: true},
+		"invalid_locale.json":         {field: "locale"},
+		"local_phone.json":            {field: "delivery.phone"},
+		"missing_delivery_cost.json":  {field: "payment.delivery_cost"},
+		"missing_sale.json":           {field: "items[0].sale"},
+		"missing_status.json":         {field: "items[0].status"},
+		"negative_delivery_cost.json": {field: "payment.delivery_cost"},
+		"negative_price.json":         {field: "items[0].price"},
+		"no_items.json":               {field: "items"},
+		"null_goods_total.json":       {field: "payment.goods_total"},
+		"sale_over_100.json":          {field: "items[0].sale"},
+		"unknown_currency.json":       {field: "payment.currency"},
+		"zero_chrt_id.json":           {field: "items[0].chrt_id"},
+		"zero_payment_dt.json":        {field: "payment.payment_dt"},
+		"zip_country_mismatch.json":   {field: "delivery.zip"},
+	}
+
+	for _, path := range testdataFiles(t, "invalid") {
+		name := filepath.Base(path)
+		t.Run(name, func(t *testing.T) {
+			tt, ok := tests[name]
+			if !ok {
+				t.Fatalf("no expected field for %s", name)
+			}
+
+			fields, err := checkOrder(t, path)
+			if tt.decode {
+				if err == nil {
+					t.Fatalf("decoded, want a decode error; rejected for %v", fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			found := false
+			for _, field := range fields {
+				found = found || field == tt.field
+			}
+			if !found {
+				t.Errorf("rejected for [%s], want %s", strings.Join(fields, ", "), tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "encoding/json"
+
+// OutboxEventOrderAccepted is published once an order is persisted
+const OutboxEventOrderAccepted = "order_accepted"
+
+// OrderAccepted is the payload of an order_accepted event
+type OrderAccepted struct {
+	OrderUID   string    `json:"order_uid"`
+	Outcome    string    `json:"outcome"` // inserted or updated
+	AcceptedAt Timestamp `json:"accepted_at"`
+	Order      *Order    `json:"order"`
+}
+
+// OutboxMessage is an event waiting in the outbox to be published. Headers
+// carry the trace context of the change that produced it.
+type OutboxMessage struct {
+	ID        int64
+	EventType string
+	Key       string
+	Payload   json.RawMessage
+	Headers   map[string]string
+	CreatedAt Timestamp
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// presence remembers which JSON keys were set when a struct was decoded, so
+// validation can tell a missing field from a legitimate zero, e.g. free
+// delivery or no sale. Structs built in code have no presence information
+// and all their fields count as present.
+type presence map[string]bool
+
+func (p presence) has(field string) bool {
+	return p == nil || p[field]
+}
+
+// presenceTracker is implemented by structs that record presence
+type presenceTracker interface {
+	isPresent(field string) bool
+}
+
+// decodePresence returns the keys of a JSON object that are set to a non-null value
+func decodePresence(data []byte) (presence, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	p := make(presence, len(fields))
+	for key, value := range fields {
+		if !bytes.Equal(value, []byte("null")) {
+			p[key] = true
+		}
+	}
+	return p, nil
+}
+
+// validatePresent implements the "present" tag: the field was set in the
+// JSON, although it may be zero
+func validatePresent(fl validator.FieldLevel) bool {
+	tracker, ok := fl.Parent().Interface().(presenceTracker)
+	if !ok {
+		return true
+	}
+	return tracker.isPresent(fl.FieldName())
+}
+
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	type plain Payment
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	var err error
+	p.present, err = decodePresence(data)
+	return err
+}
+
+func (p Payment) isPresent(field string) bool {
+	return p.present.has(field)
+}
+
+func (i *Item) UnmarshalJSON(data []byte) error {
+	type plain Item
+	if err := json.Unmarshal(data, (*plain)(i)); err != nil {
+		return err
+	}
+	var err error
+	i.present, err = decodePresence(data)
+	return err
+}
+
+func (i Item) isPresent(field string) bool {
+	return i.present.has(field)
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// QuarantinedMessage is a Kafka message that could not be processed
+// within the retry budget and was moved out of the main topic
+type QuarantinedMessage struct {
+	ID               int64             `db:"id" json:"id"`
+	Topic            string            `db:"topic" json:"topic"`
+	Partition        int               `db:"partition" json:"partition"`
+	Offset           int64             `db:"offset" json:"offset"`
+	Key              []byte            `db:"message_key" json:"key"`
+	Payload          []byte            `db:"payload" json:"payload"`
+	Headers          map[string]string `db:"headers" json:"headers"`
+	Error            string            `db:"error" json:"error"`
+	ValidationErrors []FieldError      `db:"validation_errors" json:"validation_errors,omitempty"` // set if the order failed validation
+	Attempts         int               `db:"attempts" json:"attempts"`
+	CreatedAt        time.Time         `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reference data is embedded so validation works without network access.
+// Lines starting with # are comments.
+//
+//go:embed refdata/*.txt
+var refdataFS embed.FS
+
+// country is a row of refdata/countries.txt
+type country struct {
+	code        string
+	callingCode string
+	postal      *regexp.Regexp // nil if postal codes aren't checked
+}
+
+var (
+	// currencyDigits maps ISO 4217 codes to their minor units
+	currencyDigits = loadCurrencies()
+	languages      = loadLanguages()
+	countries      = loadCountries()
+	// countriesByCallingCode lists the countries sharing a calling code,
+	// e.g. 1 is used by the US and Canada
+	countriesByCallingCode = indexCallingCodes(countries)
+)
+
+// CurrencyDigits returns the number of minor units of an ISO 4217 currency
+func CurrencyDigits(code string) (int, bool) {
+	digits, ok := currencyDigits[code]
+	return digits, ok
+}
+
+func loadCurrencies() map[string]int {
+	currencies := make(map[string]int)
+	readRefdata("currencies.txt", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("want code and minor units, got %q", fields)
+		}
+		digits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		currencies[fields[0]] = digits
+		return nil
+	})
+	return currencies
+}
+
+func loadLanguages() map[string]bool {
+	languages := make(map[string]bool)
+	readRefdata("languages.txt", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 1 {
+			return fmt.Errorf("want language code, got %q", fields)
+		}
+		languages[fields[0]] = true
+		return nil
+	})
+	return languages
+}
+
+func loadCountries() map[string]country {
+	countries := make(map[string]country)
+	readRefdata("countries.txt", func(line string) error {
+		// The postal format is the rest of the line and may contain spaces
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 2 && len(fields) != 3 {
+			return fmt.Errorf("want code, calling code and postal format, got %q", fields)
+		}
+		c := country{code: fields[0], callingCode: fields[1]}
+		if len(fields) == 3 {
+			postal, err := regexp.Compile("^(?:" + fields[2] + ")$")
+			if err != nil {
+				return err
+			}
+			c.postal = postal
+		}
+		countries[c.code] = c
+		return nil
+	})
+	return countries
+}
+
+func indexCallingCodes(countries map[string]country) map[string][]country {
+	index := make(map[string][]country)
+	for _, c := range countries {
+		index[c.callingCode] = append(index[c.callingCode], c)
+	}
+	return index
+}
+
+// readRefdata calls parse with every line of an embedded file. The data
+// ships with the binary, so a broken file is a bug and panics.
+func readRefdata(name string, parse func(line string) error) {
+	f, err := refdataFS.Open("refdata/" + name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := parse(text); err != nil {
+			panic(fmt.Sprintf("refdata/%s:%d: %v", name, line, err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+}
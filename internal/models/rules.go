@@ -0,0 +1,180 @@
+package models
+
+import "fmt"
+
+// Severity decides what happens to an order that breaks a business rule
+type Severity string
+
+const (
+	SeverityReject Severity = "reject"
+	SeverityWarn   Severity = "warn"
+)
+
+// Rule is a business check of an order that tags alone can't express.
+// Check reports the offending fields; amounts may differ by up to tolerance
+// to allow for rounding.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Check    func(order *Order, tolerance Money) []FieldError
+}
+
+// RuleEngine runs business rules against orders
+type RuleEngine struct {
+	rules     []Rule
+	tolerance Money
+}
+
+func NewRuleEngine(tolerance Money, rules ...Rule) *RuleEngine {
+	e := &RuleEngine{tolerance: tolerance}
+	for _, rule := range rules {
+		e.Register(rule)
+	}
+	return e
+}
+
+// Register adds a rule or replaces the rule with the same name
+func (e *RuleEngine) Register(rule Rule) {
+	for i := range e.rules {
+		if e.rules[i].Name == rule.Name {
+			e.rules[i] = rule
+			return
+		}
+	}
+	e.rules = append(e.rules, rule)
+}
+
+// SetSeverity changes the severity of a registered rule
+func (e *RuleEngine) SetSeverity(name string, severity Severity) error {
+	if severity != SeverityReject && severity != SeverityWarn {
+		return fmt.Errorf("unknown severity of rule %s: %q", name, severity)
+	}
+	for i := range e.rules {
+		if e.rules[i].Name == name {
+			e.rules[i].Severity = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown rule: %q", name)
+}
+
+// Check runs every rule and splits the violations by severity. The order
+// must be rejected if errs isn't empty.
+func (e *RuleEngine) Check(order *Order) (errs, warnings []FieldError) {
+	for _, rule := range e.rules {
+		for _, fe := range rule.Check(order, e.tolerance) {
+			fe.Rule = rule.Name
+			if rule.Severity == SeverityWarn {
+				warnings = append(warnings, fe)
+			} else {
+				errs = append(errs, fe)
+			}
+		}
+	}
+	return errs, warnings
+}
+
+// DefaultRules returns the built-in rules with their default severities.
+// Item totals are often rounded by the sellers, so they only warn.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "goods_total", Severity: SeverityReject, Check: checkGoodsTotal},
+		{Name: "item_total_price", Severity: SeverityWarn, Check: checkItemTotalPrice},
+		{Name: "payment_amount", Severity: SeverityReject, Check: checkPaymentAmount},
+		{Name: "item_track_number", Severity: SeverityReject, Check: checkItemTrackNumber},
+		{Name: "currency_precision", Severity: SeverityReject, Check: checkCurrencyPrecision},
+	}
+}
+
+// checkGoodsTotal: payment.goods_total is the sum of items[].total_price
+func checkGoodsTotal(order *Order, tolerance Money) []FieldError {
+	var sum Money
+	for _, item := range order.Items {
+		sum = sum.Add(item.TotalPrice)
+	}
+	if equalWithin(order.Payment.GoodsTotal, sum, tolerance) {
+		return nil
+	}
+	return []FieldError{{
+		Field:   "payment.goods_total",
+		Message: fmt.Sprintf("must equal the sum of items[].total_price (%s), got %s", sum, order.Payment.GoodsTotal),
+	}}
+}
+
+// checkItemTotalPrice: total_price is the price with the sale percent off,
+// rounded to the minor units of the currency
+func checkItemTotalPrice(order *Order, tolerance Money) []FieldError {
+	var errs []FieldError
+	for i, item := range order.Items {
+		expected := item.Price.Percent(100-item.Sale, order.Payment.Currency)
+		if !equalWithin(item.TotalPrice, expected, tolerance) {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("items[%d].total_price", i),
+				Message: fmt.Sprintf("must equal price minus sale percent (%s), got %s", expected, item.TotalPrice),
+			})
+		}
+	}
+	return errs
+}
+
+// checkPaymentAmount: payment.amount is goods_total + delivery_cost + custom_fee
+func checkPaymentAmount(order *Order, tolerance Money) []FieldError {
+	p := order.Payment
+	expected := p.GoodsTotal.Add(p.DeliveryCost).Add(p.CustomFee)
+	if equalWithin(p.Amount, expected, tolerance) {
+		return nil
+	}
+	return []FieldError{{
+		Field:   "payment.amount",
+		Message: fmt.Sprintf("must equal goods_total + delivery_cost + custom_fee (%s), got %s", expected, p.Amount),
+	}}
+}
+
+// checkCurrencyPrecision: amounts have no more decimal places than the minor
+// units of the currency, e.g. none for JPY
+func checkCurrencyPrecision(order *Order, _ Money) []FieldError {
+	currency := order.Payment.Currency
+	digits, ok := CurrencyDigits(currency)
+	if !ok {
+		// Unknown currencies are rejected by the currency tag
+		return nil
+	}
+
+	var errs []FieldError
+	check := func(field string, amount Money) {
+		if !amount.HasCurrencyPrecision(currency) {
+			errs = append(errs, FieldError{
+				Field:   field,
+				Message: fmt.Sprintf("must have at most %d decimal places in %s, got %s", digits, currency, amount),
+			})
+		}
+	}
+	p := order.Payment
+	check("payment.amount", p.Amount)
+	check("payment.delivery_cost", p.DeliveryCost)
+	check("payment.goods_total", p.GoodsTotal)
+	check("payment.custom_fee", p.CustomFee)
+	for i, item := range order.Items {
+		check(fmt.Sprintf("items[%d].price", i), item.Price)
+		check(fmt.Sprintf("items[%d].total_price", i), item.TotalPrice)
+	}
+	return errs
+}
+
+// checkItemTrackNumber: every item belongs to the shipment of the order
+func checkItemTrackNumber(order *Order, _ Money) []FieldError {
+	var errs []FieldError
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("items[%d].track_number", i),
+				Message: fmt.Sprintf("must equal the order track_number %q, got %q", order.TrackNumber, item.TrackNumber),
+			})
+		}
+	}
+	return errs
+}
+
+func equalWithin(a, b, tolerance Money) bool {
+	return a.Sub(b).Abs().Cmp(tolerance) <= 0
+}
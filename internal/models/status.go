@@ -0,0 +1,38 @@
+package models
+
+// OrderStatus is a stage of the order lifecycle
+type OrderStatus string
+
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+// StatusChange is a status-change event and, once applied, an entry of the
+// order's status history. From is empty for the initial created status.
+type StatusChange struct {
+	OrderUID   string      `db:"order_uid" json:"order_uid" validate:"required,alphanum"`
+	From       OrderStatus `db:"from_status" json:"from,omitempty"`
+	To         OrderStatus `db:"to_status" json:"status" validate:"required,oneof=created paid assembling shipped delivered cancelled returned"`
+	Reason     string      `db:"reason" json:"reason,omitempty"`
+	OccurredAt Timestamp   `db:"occurred_at" json:"occurred_at"`
+	RecordedAt Timestamp   `db:"recorded_at" json:"recorded_at"`
+}
+
+// OrderHistory is the current status of an order and how it got there,
+// oldest change first
+type OrderHistory struct {
+	OrderUID string         `json:"order_uid"`
+	Status   OrderStatus    `json:"status"`
+	History  []StatusChange `json:"history"`
+}
+
+// ValidateStatusChange returns a *ValidationError listing every invalid field
+func ValidateStatusChange(change *StatusChange) error {
+	return validateStruct(change)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestamp is a point in time kept in UTC with the microsecond precision
+// of TIMESTAMPTZ, so it doesn't change on the way through the database. It
+// is rendered in RFC 3339, e.g. 2021-11-26T06:22:19Z.
+type Timestamp struct {
+	time.Time
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t.UTC().Truncate(time.Microsecond)}
+}
+
+// ParseTimestamp accepts any RFC 3339 timestamp: with or without fractional
+// seconds, with Z or an offset, in either case and with a space instead of T
+func ParseTimestamp(s string) (Timestamp, error) {
+	normalized := strings.ToUpper(s)
+	if len(normalized) > 10 && normalized[10] == ' ' {
+		normalized = normalized[:10] + "T" + normalized[11:]
+	}
+	t, err := time.Parse(time.RFC3339Nano, normalized)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q, must be RFC 3339", s)
+	}
+	return NewTimestamp(t), nil
+}
+
+// String formats the timestamp in RFC 3339 in UTC
+func (t Timestamp) String() string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON accepts an RFC 3339 string or, as payment_dt always was, a
+// number of Unix seconds. null leaves t unchanged.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseTimestamp(s)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+	var seconds int64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("invalid timestamp %s, must be RFC 3339 or Unix seconds", data)
+	}
+	*t = NewTimestamp(time.Unix(seconds, 0))
+	return nil
+}
+
+// Value stores t in a TIMESTAMPTZ column
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC(), nil
+}
+
+func (t *Timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = NewTimestamp(v)
+	case nil:
+		*t = Timestamp{}
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", src)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "2021-11-26T06:22:19Z", want: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-26t06:22:19z", want: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-26 06:22:19Z", want: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-26T09:22:19+03:00", want: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-25T23:52:19-06:30", want: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-26T06:22:19.5Z", want: "2021-11-26T06:22:19.5Z"},
+		{in: "2021-11-26T06:22:19.123456Z", want: "2021-11-26T06:22:19.123456Z"},
+		// Nanoseconds are truncated to the precision of TIMESTAMPTZ
+		{in: "2021-11-26T06:22:19.123456789Z", want: "2021-11-26T06:22:19.123456Z"},
+		{in: "2021-11-26T09:22:19.25+03:00", want: "2021-11-26T06:22:19.25Z"},
+		{in: "2021-11-26", wantErr: true},
+		{in: "2021-11-26T06:22:19", wantErr: true},
+		{in: "26.11.2021 06:22:19", wantErr: true},
+		{in: "2021-11-26T06:22:19+0300", wantErr: true},
+		{in: "1637907739", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTimestamp(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTimestamp(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+		if got.Location() != time.UTC {
+			t.Errorf("ParseTimestamp(%q) is in %s, want UTC", tt.in, got.Location())
+		}
+	}
+}
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `"2021-11-26T06:22:19Z"`, want: "2021-11-26T06:22:19Z"},
+		{in: `"2021-11-26T09:22:19.5+03:00"`, want: "2021-11-26T06:22:19.5Z"},
+		// payment_dt is sent as Unix seconds
+		{in: `1637907739`, want: "2021-11-26T06:22:19Z"},
+		{in: `0`, want: "1970-01-01T00:00:00Z"},
+		{in: `null`, want: "2000-01-01T00:00:00Z"},
+		{in: `1637907739.5`, wantErr: true},
+		{in: `"1637907739"`, wantErr: true},
+		{in: `"2021-11-26"`, wantErr: true},
+		{in: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		// null leaves the previous value
+		ts := NewTimestamp(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+		err := json.Unmarshal([]byte(tt.in), &ts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %s, want an error", tt.in, ts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if ts.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, ts, tt.want)
+		}
+	}
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		in   Timestamp
+		want string
+	}{
+		{NewTimestamp(time.Date(2021, 11, 26, 9, 22, 19, 0, moscow)), `"2021-11-26T06:22:19Z"`},
+		{NewTimestamp(time.Date(2021, 11, 26, 6, 22, 19, 1500, time.UTC)), `"2021-11-26T06:22:19.000001Z"`},
+		{Timestamp{}, `null`},
+	}
+
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.in.Time, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes a field that broke a validation rule
+type FieldError struct {
+	Field   string `json:"field"` // JSON path, e.g. items[2].price
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of an order that failed validation
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func newValidationError(errs validator.ValidationErrors) *ValidationError {
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		// The namespace starts with the name of the validated struct
+		field := fe.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		fields[i] = FieldError{Field: field, Rule: fe.Tag(), Message: ruleMessage(fe)}
+	}
+	return &ValidationError{Fields: fields}
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "present":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "min":
+		return "must not have fewer than " + fe.Param() + " elements"
+	case "email":
+		return "must be a valid email address"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "phone":
+		return "must be a phone number in the E.164 format with a known country code, e.g. +79001234567"
+	case "currency":
+		return "must be an ISO 4217 currency code, e.g. RUB"
+	case "locale":
+		return "must be a BCP 47 language tag, e.g. ru or en-US"
+	case "postcode":
+		return "must be a postal code of the country of delivery.phone"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "datetime":
+		return fmt.Sprintf("must be a timestamp in the %s format", fe.Param())
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), fe.Param())
+	}
+	return "must satisfy " + fe.Tag()
+}
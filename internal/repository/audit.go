@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"context"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OrderEventDB is a row of order_events. Snapshots are kept as any, so that a
+// missing one is written and read as NULL.
+type OrderEventDB struct {
+	ID         int64              `db:"id"`
+	OrderUID   string             `db:"order_uid"`
+	Type       models.EventType   `db:"event_type"`
+	Previous   any                `db:"previous_data"`
+	New        any                `db:"new_data"`
+	Source     models.EventSource `db:"source"`
+	Actor      string             `db:"actor"`
+	OccurredAt models.Timestamp   `db:"occurred_at"`
+}
+
+const insertOrderEventQuery = `INSERT INTO order_events (
+		order_uid, event_type, previous_data, new_data, source, actor
+	) VALUES (
+		:order_uid, :event_type, :previous_data, :new_data, :source, :actor
+	)`
+
+// newOrderEvent describes a change of the order made by the origin
+func newOrderEvent(origin models.Origin, orderUID string, eventType models.EventType, previous, data []byte) *OrderEventDB {
+	return &OrderEventDB{
+		OrderUID: orderUID,
+		Type:     eventType,
+		Previous: nullJSON(previous),
+		New:      nullJSON(data),
+		Source:   origin.Source,
+		Actor:    origin.Actor,
+	}
+}
+
+// ToModel converts the row to models.OrderEvent
+func (e *OrderEventDB) ToModel() models.OrderEvent {
+	event := models.OrderEvent{
+		ID:         e.ID,
+		OrderUID:   e.OrderUID,
+		Type:       e.Type,
+		Source:     e.Source,
+		Actor:      e.Actor,
+		OccurredAt: e.OccurredAt,
+	}
+	if data, ok := e.Previous.([]byte); ok {
+		event.Previous = data
+	}
+	if data, ok := e.New.([]byte); ok {
+		event.New = data
+	}
+	return event
+}
+
+// insertCreatedEvents records the creation of new orders
+func insertCreatedEvents(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	events := make([]*OrderEventDB, len(orders))
+	for i, order := range orders {
+		data, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		events[i] = newOrderEvent(models.OrderOrigin(ctx, order), order.OrderUID, models.EventCreated, nil, data)
+	}
+	return bulkExec(ctx, tx, insertOrderEventQuery, events)
+}
+
+// insertOrderEvent records an event of a single order with its JSON before
+// and after the event
+func insertOrderEvent(ctx context.Context, tx *sqlx.Tx, order *models.Order, eventType models.EventType, previous, data []byte) error {
+	event := newOrderEvent(models.OrderOrigin(ctx, order), order.OrderUID, eventType, previous, data)
+	_, err := tx.NamedExecContext(ctx, insertOrderEventQuery, event)
+	return err
+}
+
+// insertStatusEvent records a status change in the audit trail
+func insertStatusEvent(ctx context.Context, tx *sqlx.Tx, change *models.StatusChange) error {
+	previous, err := json.Marshal(map[string]models.OrderStatus{"status": change.From})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]models.OrderStatus{"status": change.To})
+	if err != nil {
+		return err
+	}
+	event := newOrderEvent(models.OriginFrom(ctx), change.OrderUID, models.EventStatusChanged, previous, data)
+	_, err = tx.NamedExecContext(ctx, insertOrderEventQuery, event)
+	return err
+}
+
+// GetOrderEvents returns the audit trail of the order, oldest first
+func (r *PostgresRepository) GetOrderEvents(ctx context.Context, orderUID string) ([]models.OrderEvent, error) {
+	var rows []OrderEventDB
+	query := `SELECT id, order_uid, event_type, previous_data, new_data, source, actor, occurred_at
+		FROM order_events WHERE order_uid = $1 ORDER BY id`
+	if err := r.db.SelectContext(ctx, &rows, query, orderUID); err != nil {
+		return nil, err
+	}
+
+	events := make([]models.OrderEvent, len(rows))
+	for i := range rows {
+		events[i] = rows[i].ToModel()
+	}
+	return events, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	// idempotencyKeyTTL is how long a completed response is replayed
+	idempotencyKeyTTL = 24 * time.Hour
+	// IdempotentRequestTimeout is the longest a request may hold its key, the
+	// server cancels the request when it runs out
+	IdempotentRequestTimeout = 2 * time.Minute
+	// idempotencyLockTimeout is how long a key may stay in progress before it's
+	// considered abandoned, e.g. after a crash. It outlasts the request, so a
+	// slow request is never run twice at once.
+	idempotencyLockTimeout = IdempotentRequestTimeout + 30*time.Second
+)
+
+func (r *PostgresRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	record, err := r.reserveIdempotencyKey(ctx, key, requestHash)
+	// The key was released between the insert and the select, so it's free now
+	if errors.Is(err, sql.ErrNoRows) {
+		record, err = r.reserveIdempotencyKey(ctx, key, requestHash)
+	}
+	return record, err
+}
+
+func (r *PostgresRepository) reserveIdempotencyKey(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	query := `INSERT INTO idempotency_keys (key, request_hash, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response = NULL,
+			created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $4
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $5)
+		RETURNING key`
+
+	rows, err := r.db.QueryContext(ctx, query, key, requestHash, now,
+		now.Add(-idempotencyKeyTTL), now.Add(-idempotencyLockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	reserved := rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	var record models.IdempotencyRecord
+	query = `SELECT key, request_hash, COALESCE(status_code, 0) AS status_code, response, created_at
+		FROM idempotency_keys WHERE key = $1`
+	if err := r.db.GetContext(ctx, &record, query, key); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *PostgresRepository) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, response []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $2, response = $3 WHERE key = $1`
+	_, err := r.db.ExecContext(ctx, query, key, statusCode, response)
+	return err
+}
+
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`
+	_, err := r.db.ExecContext(ctx, query, key)
+	return err
+}
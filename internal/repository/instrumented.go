@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"L0/internal/metrics"
+	"L0/internal/models"
+	"L0/internal/tracing"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("repository")
+
+// instrumentedRepository records the latency of every operation of the
+// wrapped repository and traces it
+type instrumentedRepository struct {
+	next OrderRepository
+}
+
+func newInstrumentedRepository(next OrderRepository) OrderRepository {
+	return &instrumentedRepository{next: next}
+}
+
+// operation tracks a single repository call
+type operation struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+func startOperation(ctx context.Context, name string) (context.Context, *operation) {
+	ctx, span := tracer.Start(ctx, "PostgresRepository."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+	return ctx, &operation{name: name, start: time.Now(), span: span}
+}
+
+func (o *operation) end(err error) {
+	metrics.QueryDuration.WithLabelValues(o.name, metrics.Status(err)).Observe(time.Since(o.start).Seconds())
+	tracing.End(o.span, err)
+}
+
+// endLookup ends a lookup, for which a missing row is a normal result rather
+// than an error
+func (o *operation) endLookup(err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		metrics.QueryDuration.WithLabelValues(o.name, "not_found").Observe(time.Since(o.start).Seconds())
+		o.span.End()
+		return
+	}
+	o.end(err)
+}
+
+func (r *instrumentedRepository) SaveOrder(ctx context.Context, order *models.Order) (SaveOutcome, error) {
+	ctx, op := startOperation(ctx, "save_order")
+	outcome, err := r.next.SaveOrder(ctx, order)
+	op.end(err)
+	return outcome, err
+}
+
+func (r *instrumentedRepository) SaveOrders(ctx context.Context, orders []*models.Order) ([]SaveOutcome, error) {
+	ctx, op := startOperation(ctx, "save_orders")
+	outcomes, err := r.next.SaveOrders(ctx, orders)
+	op.end(err)
+	return outcomes, err
+}
+
+func (r *instrumentedRepository) RunMigrations(migrationsPath string) error {
+	return r.next.RunMigrations(migrationsPath)
+}
+
+func (r *instrumentedRepository) Ping(ctx context.Context) error {
+	ctx, op := startOperation(ctx, "ping")
+	err := r.next.Ping(ctx)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error) {
+	ctx, op := startOperation(ctx, "get_order_by_id")
+	order, err := r.next.GetOrderByID(ctx, orderUID)
+	op.endLookup(err)
+	return order, err
+}
+
+func (r *instrumentedRepository) StreamOrders(ctx context.Context, opts StreamOptions, fn func([]*models.Order) error) error {
+	ctx, op := startOperation(ctx, "stream_orders")
+	err := r.next.StreamOrders(ctx, opts, fn)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) ListOrders(ctx context.Context, filter models.OrderFilter) (*models.OrderPage, error) {
+	ctx, op := startOperation(ctx, "list_orders")
+	page, err := r.next.ListOrders(ctx, filter)
+	op.end(err)
+	return page, err
+}
+
+func (r *instrumentedRepository) SaveQuarantinedMessage(ctx context.Context, msg *models.QuarantinedMessage) error {
+	ctx, op := startOperation(ctx, "save_quarantined_message")
+	err := r.next.SaveQuarantinedMessage(ctx, msg)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	ctx, op := startOperation(ctx, "reserve_idempotency_key")
+	record, err := r.next.ReserveIdempotencyKey(ctx, key, requestHash)
+	op.end(err)
+	return record, err
+}
+
+func (r *instrumentedRepository) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, response []byte) error {
+	ctx, op := startOperation(ctx, "complete_idempotency_key")
+	err := r.next.CompleteIdempotencyKey(ctx, key, statusCode, response)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, op := startOperation(ctx, "release_idempotency_key")
+	err := r.next.ReleaseIdempotencyKey(ctx, key)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) GetOrderStatus(ctx context.Context, orderUID string) (models.OrderStatus, error) {
+	ctx, op := startOperation(ctx, "get_order_status")
+	status, err := r.next.GetOrderStatus(ctx, orderUID)
+	op.endLookup(err)
+	return status, err
+}
+
+func (r *instrumentedRepository) ChangeOrderStatus(ctx context.Context, change *models.StatusChange) error {
+	ctx, op := startOperation(ctx, "change_order_status")
+	err := r.next.ChangeOrderStatus(ctx, change)
+	op.end(err)
+	return err
+}
+
+func (r *instrumentedRepository) GetStatusHistory(ctx context.Context, orderUID string) ([]models.StatusChange, error) {
+	ctx, op := startOperation(ctx, "get_status_history")
+	history, err := r.next.GetStatusHistory(ctx, orderUID)
+	op.endLookup(err)
+	return history, err
+}
+
+func (r *instrumentedRepository) GetOrderEvents(ctx context.Context, orderUID string) ([]models.OrderEvent, error) {
+	ctx, op := startOperation(ctx, "get_order_events")
+	events, err := r.next.GetOrderEvents(ctx, orderUID)
+	op.endLookup(err)
+	return events, err
+}
+
+func (r *instrumentedRepository) RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxMessage) error) (int, error) {
+	ctx, op := startOperation(ctx, "relay_outbox")
+	n, err := r.next.RelayOutbox(ctx, limit, publish)
+	op.end(err)
+	return n, err
+}
+
+func (r *instrumentedRepository) DeleteSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error) {
+	ctx, op := startOperation(ctx, "delete_sent_outbox")
+	n, err := r.next.DeleteSentOutbox(ctx, sentBefore)
+	op.end(err)
+	return n, err
+}
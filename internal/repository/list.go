@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// orderCursor points right after the last order of a page. It remembers
+// the sorting so that it can't be reused with a different one.
+type orderCursor struct {
+	SortBy   string `json:"s"`
+	Desc     bool   `json:"d"`
+	Value    string `json:"v"`
+	OrderUID string `json:"u"`
+}
+
+func (c orderCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (orderCursor, error) {
+	var c orderCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// ListOrders searches orders with keyset pagination, ties on the sort
+// column are broken by order_uid
+func (r *PostgresRepository) ListOrders(ctx context.Context, filter models.OrderFilter) (*models.OrderPage, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = models.SortByDateCreated
+	}
+	if sortBy != models.SortByDateCreated && sortBy != models.SortByOrderUID {
+		return nil, fmt.Errorf("unknown sort column: %q", sortBy)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.CustomerID != "" {
+		conds = append(conds, "o.customer_id = "+arg(filter.CustomerID))
+	}
+	if filter.TrackNumber != "" {
+		conds = append(conds, "o.track_number = "+arg(filter.TrackNumber))
+	}
+	if filter.DeliveryService != "" {
+		conds = append(conds, "o.delivery_service = "+arg(filter.DeliveryService))
+	}
+	if filter.Locale != "" {
+		conds = append(conds, "o.locale = "+arg(filter.Locale))
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, "o.date_created >= "+arg(filter.CreatedFrom.UTC()))
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, "o.date_created <= "+arg(filter.CreatedTo.UTC()))
+	}
+
+	var paymentConds []string
+	if filter.Provider != "" {
+		paymentConds = append(paymentConds, "p.provider = "+arg(filter.Provider))
+	}
+	if filter.Bank != "" {
+		paymentConds = append(paymentConds, "p.bank = "+arg(filter.Bank))
+	}
+	if len(paymentConds) > 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = o.order_uid AND "+
+			strings.Join(paymentConds, " AND ")+")")
+	}
+
+	// Both item conditions must hold for the same item
+	var itemConds []string
+	if filter.Brand != "" {
+		itemConds = append(itemConds, "i.brand = "+arg(filter.Brand))
+	}
+	if filter.NmID != 0 {
+		itemConds = append(itemConds, "i.nm_id = "+arg(filter.NmID))
+	}
+	if len(itemConds) > 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND "+
+			strings.Join(itemConds, " AND ")+")")
+	}
+
+	cmp, dir := ">", "ASC"
+	if filter.Desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != sortBy || cursor.Desc != filter.Desc {
+			return nil, ErrInvalidCursor
+		}
+
+		if sortBy == models.SortByOrderUID {
+			conds = append(conds, "o.order_uid "+cmp+" "+arg(cursor.OrderUID))
+		} else {
+			conds = append(conds, fmt.Sprintf("(o.date_created, o.order_uid) %s (%s::timestamptz, %s)",
+				cmp, arg(cursor.Value), arg(cursor.OrderUID)))
+		}
+	}
+
+	query := `SELECT ` + prefixColumns("o", orderColumns) + ` FROM orders o`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	if sortBy == models.SortByOrderUID {
+		query += ` ORDER BY o.order_uid ` + dir
+	} else {
+		query += ` ORDER BY o.date_created ` + dir + `, o.order_uid ` + dir
+	}
+	// One extra row tells whether there is a next page
+	query += ` LIMIT ` + arg(limit+1)
+
+	var ordersDB []OrderDB
+	if err := r.db.SelectContext(ctx, &ordersDB, query, args...); err != nil {
+		return nil, err
+	}
+
+	page := &models.OrderPage{}
+	if len(ordersDB) > limit {
+		ordersDB = ordersDB[:limit]
+		last := ordersDB[limit-1]
+		page.NextCursor = orderCursor{
+			SortBy:   sortBy,
+			Desc:     filter.Desc,
+			Value:    last.DateCreated.String(),
+			OrderUID: last.OrderUID,
+		}.encode()
+	}
+
+	orders, err := loadOrders(ctx, r.db, ordersDB)
+	if err != nil {
+		return nil, err
+	}
+	page.Orders = orders
+
+	return page, nil
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, column := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(column)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// OutboxDB is a row of the outbox table
+type OutboxDB struct {
+	ID        int64            `db:"id"`
+	EventType string           `db:"event_type"`
+	Key       string           `db:"message_key"`
+	Payload   []byte           `db:"payload"`
+	Headers   []byte           `db:"headers"`
+	CreatedAt models.Timestamp `db:"created_at"`
+}
+
+const insertOutboxQuery = `INSERT INTO outbox (event_type, message_key, payload, headers)
+	VALUES (:event_type, :message_key, :payload, :headers)`
+
+// ToModel converts the row to models.OutboxMessage
+func (o *OutboxDB) ToModel() (models.OutboxMessage, error) {
+	msg := models.OutboxMessage{
+		ID:        o.ID,
+		EventType: o.EventType,
+		Key:       o.Key,
+		Payload:   o.Payload,
+		CreatedAt: o.CreatedAt,
+	}
+	if err := json.Unmarshal(o.Headers, &msg.Headers); err != nil {
+		return models.OutboxMessage{}, err
+	}
+	return msg, nil
+}
+
+// insertOrderAccepted queues order_accepted events of the saved orders, so
+// that they are published if and only if the transaction commits
+func (r *PostgresRepository) insertOrderAccepted(ctx context.Context, tx *sqlx.Tx, orders []*models.Order, outcome SaveOutcome) error {
+	if !r.outbox || len(orders) == 0 {
+		return nil
+	}
+
+	// The trace context lets consumers of the event continue the trace of
+	// the change
+	headers := map[string]string{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	acceptedAt := models.NewTimestamp(time.Now())
+	rows := make([]*OutboxDB, len(orders))
+	for i, order := range orders {
+		payload, err := json.Marshal(models.OrderAccepted{
+			OrderUID:   order.OrderUID,
+			Outcome:    outcome.String(),
+			AcceptedAt: acceptedAt,
+			Order:      order,
+		})
+		if err != nil {
+			return err
+		}
+		rows[i] = &OutboxDB{
+			EventType: models.OutboxEventOrderAccepted,
+			Key:       order.OrderUID,
+			Payload:   payload,
+			Headers:   headersJSON,
+		}
+	}
+	return bulkExec(ctx, tx, insertOutboxQuery, rows)
+}
+
+// RelayOutbox passes up to limit unsent messages, oldest first, to publish
+// and marks them as sent if it succeeds. The rows stay locked meanwhile, so
+// concurrent relays take different messages. A crash after publishing leaves
+// the messages unsent, they are published again.
+func (r *PostgresRepository) RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxMessage) error) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var rows []OutboxDB
+	query := `SELECT id, event_type, message_key, payload, headers, created_at FROM outbox
+		WHERE sent_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`
+	if err := tx.SelectContext(ctx, &rows, query, limit); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	msgs := make([]models.OutboxMessage, len(rows))
+	ids := make([]int64, len(rows))
+	for i := range rows {
+		if msgs[i], err = rows[i].ToModel(); err != nil {
+			return 0, err
+		}
+		ids[i] = rows[i].ID
+	}
+
+	if err := publish(msgs); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox SET sent_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return len(msgs), tx.Commit()
+}
+
+// DeleteSentOutbox removes messages sent before the given time
+func (r *PostgresRepository) DeleteSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < $1`, sentBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
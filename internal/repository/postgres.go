@@ -3,12 +3,14 @@ package repository
 import (
 	"L0/internal/config"
 	"L0/internal/models"
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
@@ -16,10 +18,19 @@ import (
 )
 
 type PostgresRepository struct {
-	db *sqlx.DB
+	db             *sqlx.DB
+	conflictPolicy ConflictPolicy
+	outbox         bool // queue order_accepted events of saved orders
 }
 
 func NewPostgresRepository(cfg *config.Config) (OrderRepository, error) {
+	policy := ConflictPolicy(cfg.Orders.ConflictPolicy)
+	switch policy {
+	case ConflictReject, ConflictLastWriteWins, ConflictNewerWins:
+	default:
+		return nil, fmt.Errorf("unknown order conflict policy: %q", policy)
+	}
+
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Postgres.Host,
 		cfg.Postgres.Port,
@@ -36,7 +47,11 @@ func NewPostgresRepository(cfg *config.Config) (OrderRepository, error) {
 		return nil, err
 	}
 
-	return &PostgresRepository{db: db}, nil
+	return newInstrumentedRepository(&PostgresRepository{
+		db:             db,
+		conflictPolicy: policy,
+		outbox:         cfg.Outbox.Topic != "",
+	}), nil
 }
 
 func (r *PostgresRepository) RunMigrations(migrationsPath string) error {
@@ -60,40 +75,46 @@ func (r *PostgresRepository) RunMigrations(migrationsPath string) error {
 	return nil
 }
 
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
 // OrderDB is a helper struct for reading orders from db
 type OrderDB struct {
-	OrderUID          string          `db:"order_uid"`
-	TrackNumber       string          `db:"track_number"`
-	Entry             string          `db:"entry"`
-	Delivery          json.RawMessage `db:"delivery"`
-	Payment           json.RawMessage `db:"payment"`
-	Items             json.RawMessage `db:"items"`
-	Locale            string          `db:"locale"`
-	InternalSignature string          `db:"internal_signature"`
-	CustomerID        string          `db:"customer_id"`
-	DeliveryService   string          `db:"delivery_service"`
-	ShardKey          string          `db:"shardkey"`
-	SmID              int             `db:"sm_id"`
-	DateCreated       string          `db:"date_created"`
-	OofShard          string          `db:"oof_shard"`
-}
-
-// ToModel converts OrderDB into models.Order
-func (o *OrderDB) ToModel() (*models.Order, error) {
-	var delivery models.Delivery
-	var payment models.Payment
-	var items []models.Item
-
-	if err := json.Unmarshal(o.Delivery, &delivery); err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(o.Payment, &payment); err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal(o.Items, &items); err != nil {
-		return nil, err
-	}
+	OrderUID          string           `db:"order_uid"`
+	TrackNumber       string           `db:"track_number"`
+	Entry             string           `db:"entry"`
+	Locale            string           `db:"locale"`
+	InternalSignature string           `db:"internal_signature"`
+	CustomerID        string           `db:"customer_id"`
+	DeliveryService   string           `db:"delivery_service"`
+	ShardKey          string           `db:"shardkey"`
+	SmID              int              `db:"sm_id"`
+	DateCreated       models.Timestamp `db:"date_created"`
+	OofShard          string           `db:"oof_shard"`
+}
 
+// DeliveryDB is a row of the deliveries table
+type DeliveryDB struct {
+	OrderUID string `db:"order_uid"`
+	models.Delivery
+}
+
+// PaymentDB is a row of the payments table
+type PaymentDB struct {
+	OrderUID string `db:"order_uid"`
+	models.Payment
+}
+
+// ItemDB is a row of the items table, position keeps the order of items
+type ItemDB struct {
+	OrderUID string `db:"order_uid"`
+	Position int    `db:"position"`
+	models.Item
+}
+
+// ToModel assembles models.Order from the order row and its details
+func (o *OrderDB) ToModel(delivery models.Delivery, payment models.Payment, items []models.Item) *models.Order {
 	return &models.Order{
 		OrderUID:          o.OrderUID,
 		TrackNumber:       o.TrackNumber,
@@ -109,87 +130,446 @@ func (o *OrderDB) ToModel() (*models.Order, error) {
 		SmID:              o.SmID,
 		DateCreated:       o.DateCreated,
 		OofShard:          o.OofShard,
-	}, nil
+	}
+}
+
+// FromModel splits models.Order into rows of the orders, deliveries,
+// payments and items tables
+func FromModel(order *models.Order) (*OrderDB, *DeliveryDB, *PaymentDB, []*ItemDB) {
+	items := make([]*ItemDB, len(order.Items))
+	for i, item := range order.Items {
+		items[i] = &ItemDB{OrderUID: order.OrderUID, Position: i, Item: item}
+	}
+
+	return &OrderDB{
+			OrderUID:          order.OrderUID,
+			TrackNumber:       order.TrackNumber,
+			Entry:             order.Entry,
+			Locale:            order.Locale,
+			InternalSignature: order.InternalSignature,
+			CustomerID:        order.CustomerID,
+			DeliveryService:   order.DeliveryService,
+			ShardKey:          order.ShardKey,
+			SmID:              order.SmID,
+			DateCreated:       order.DateCreated,
+			OofShard:          order.OofShard,
+		},
+		&DeliveryDB{OrderUID: order.OrderUID, Delivery: order.Delivery},
+		&PaymentDB{OrderUID: order.OrderUID, Payment: order.Payment},
+		items
 }
 
-// FromModel converts models.Order into OrderDB
-func FromModel(order *models.Order) (*OrderDB, error) {
-	deliveryJSON, err := json.Marshal(order.Delivery)
+const (
+	orderColumns = `order_uid, track_number, entry, locale, internal_signature, customer_id,
+		delivery_service, shardkey, sm_id, date_created, oof_shard`
+
+	insertOrderQuery = `INSERT INTO orders (
+		order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+	) VALUES (
+		:order_uid, :track_number, :entry, :locale, :internal_signature, :customer_id, :delivery_service, :shardkey, :sm_id, :date_created, :oof_shard
+	) ON CONFLICT (order_uid) DO NOTHING`
+
+	insertDeliveryQuery = `INSERT INTO deliveries (
+		order_uid, name, phone, zip, city, address, region, email
+	) VALUES (
+		:order_uid, :name, :phone, :zip, :city, :address, :region, :email
+	)`
+
+	insertPaymentQuery = `INSERT INTO payments (
+		order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+	) VALUES (
+		:order_uid, :transaction, :request_id, :currency, :provider, :amount, :payment_dt, :bank, :delivery_cost, :goods_total, :custom_fee
+	)`
+
+	insertItemQuery = `INSERT INTO items (
+		order_uid, position, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+	) VALUES (
+		:order_uid, :position, :chrt_id, :track_number, :price, :rid, :name, :sale, :size, :total_price, :nm_id, :brand, :status
+	)`
+)
+
+// bulkInsertChunk keeps multi-row inserts below the Postgres limit of 65535 parameters
+const bulkInsertChunk = 1000
+
+// defaultStreamBatchSize is the number of orders StreamOrders fetches at once
+// unless told otherwise
+const defaultStreamBatchSize = 500
+
+// SaveOrder inserts the order or, if it already exists, resolves the
+// conflict according to the configured policy
+func (r *PostgresRepository) SaveOrder(ctx context.Context, order *models.Order) (SaveOutcome, error) {
+	orderDB, _, _, _ := FromModel(order)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return OrderNotSaved, err
 	}
-	paymentJSON, err := json.Marshal(order.Payment)
+	defer tx.Rollback()
+
+	res, err := tx.NamedExecContext(ctx, insertOrderQuery, orderDB)
 	if err != nil {
-		return nil, err
+		return OrderNotSaved, err
 	}
-	itemsJSON, err := json.Marshal(order.Items)
+	inserted, err := res.RowsAffected()
 	if err != nil {
-		return nil, err
+		return OrderNotSaved, err
+	}
+	if inserted == 1 {
+		if err := insertOrderDetails(ctx, tx, []*models.Order{order}); err != nil {
+			return OrderNotSaved, err
+		}
+		if err := insertInitialStatus(ctx, tx, []*models.Order{order}); err != nil {
+			return OrderNotSaved, err
+		}
+		if err := insertCreatedEvents(ctx, tx, []*models.Order{order}); err != nil {
+			return OrderNotSaved, err
+		}
+		if err := r.insertOrderAccepted(ctx, tx, []*models.Order{order}, OrderInserted); err != nil {
+			return OrderNotSaved, err
+		}
+		return OrderInserted, tx.Commit()
 	}
 
-	return &OrderDB{
-		OrderUID:          order.OrderUID,
-		TrackNumber:       order.TrackNumber,
-		Entry:             order.Entry,
-		Delivery:          deliveryJSON,
-		Payment:           paymentJSON,
-		Items:             itemsJSON,
-		Locale:            order.Locale,
-		InternalSignature: order.InternalSignature,
-		CustomerID:        order.CustomerID,
-		DeliveryService:   order.DeliveryService,
-		ShardKey:          order.ShardKey,
-		SmID:              order.SmID,
-		DateCreated:       order.DateCreated,
-		OofShard:          order.OofShard,
-	}, nil
-}
-
-func (r *PostgresRepository) SaveOrder(ctx context.Context, order *models.Order) error {
-	orderDB, err := FromModel(order)
+	var existingDB []OrderDB
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE order_uid = $1 FOR UPDATE`
+	if err := tx.SelectContext(ctx, &existingDB, query, order.OrderUID); err != nil {
+		return OrderNotSaved, err
+	}
+	existing, err := loadOrders(ctx, tx, existingDB)
 	if err != nil {
-		return err
+		return OrderNotSaved, err
+	}
+	if len(existing) == 0 {
+		return OrderNotSaved, sql.ErrNoRows
 	}
 
-	query := `INSERT INTO orders (
-		order_uid, track_number, entry, delivery, payment, items, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-	) VALUES (
-		:order_uid, :track_number, :entry, :delivery, :payment, :items, :locale, :internal_signature, :customer_id, :delivery_service, :shardkey, :sm_id, :date_created, :oof_shard
-	)`
+	// Orders are compared by their JSON, which also goes to the audit trail
+	previous, err := json.Marshal(&existing[0])
+	if err != nil {
+		return OrderNotSaved, err
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		return OrderNotSaved, err
+	}
+	// Orders that change nothing are still recorded in the audit trail
+	if bytes.Equal(previous, data) {
+		if err := insertOrderEvent(ctx, tx, order, models.EventRedelivered, previous, data); err != nil {
+			return OrderNotSaved, err
+		}
+		return OrderUnchanged, tx.Commit()
+	}
 
-	_, err = r.db.NamedExecContext(ctx, query, orderDB)
-	return err
+	switch r.conflictPolicy {
+	case ConflictReject:
+		if err := insertOrderEvent(ctx, tx, order, models.EventRejected, previous, data); err != nil {
+			return OrderNotSaved, err
+		}
+		if err := tx.Commit(); err != nil {
+			return OrderNotSaved, err
+		}
+		return OrderConflict, ErrOrderConflict
+	case ConflictNewerWins:
+		if !order.DateCreated.After(existing[0].DateCreated.Time) {
+			if err := insertOrderEvent(ctx, tx, order, models.EventRejected, previous, data); err != nil {
+				return OrderNotSaved, err
+			}
+			return OrderStale, tx.Commit()
+		}
+	}
+
+	query = `UPDATE orders SET
+		track_number = :track_number, entry = :entry, locale = :locale,
+		internal_signature = :internal_signature, customer_id = :customer_id,
+		delivery_service = :delivery_service, shardkey = :shardkey, sm_id = :sm_id,
+		date_created = :date_created, oof_shard = :oof_shard
+	WHERE order_uid = :order_uid`
+
+	if _, err := tx.NamedExecContext(ctx, query, orderDB); err != nil {
+		return OrderNotSaved, err
+	}
+
+	// Details are replaced as a whole, items may have been added or removed
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid = $1`, order.OrderUID); err != nil {
+			return OrderNotSaved, err
+		}
+	}
+	if err := insertOrderDetails(ctx, tx, []*models.Order{order}); err != nil {
+		return OrderNotSaved, err
+	}
+
+	if err := insertOrderEvent(ctx, tx, order, models.EventUpdated, previous, data); err != nil {
+		return OrderNotSaved, err
+	}
+	if err := r.insertOrderAccepted(ctx, tx, []*models.Order{order}, OrderUpdated); err != nil {
+		return OrderNotSaved, err
+	}
+
+	return OrderUpdated, tx.Commit()
 }
 
-func (r *PostgresRepository) GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error) {
-	var orderDB OrderDB
-	query := `SELECT * FROM orders WHERE order_uid = $1`
+// SaveOrders inserts new orders with multi-row inserts in a single transaction.
+// Orders that already exist, including repeated order_uids within the batch,
+// are left untouched and reported as OrderNotSaved for the caller to resolve
+// with SaveOrder, which also records their events. On error nothing is written.
+func (r *PostgresRepository) SaveOrders(ctx context.Context, orders []*models.Order) ([]SaveOutcome, error) {
+	outcomes := make([]SaveOutcome, len(orders))
+	ordersDB := make([]*OrderDB, len(orders))
+	for i, order := range orders {
+		ordersDB[i], _, _, _ = FromModel(order)
+	}
 
-	err := r.db.GetContext(ctx, &orderDB, query, orderUID)
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	inserted := make(map[string]bool, len(orders))
+	for start := 0; start < len(ordersDB); start += bulkInsertChunk {
+		end := min(start+bulkInsertChunk, len(ordersDB))
+
+		query, args, err := sqlx.Named(insertOrderQuery+` RETURNING order_uid`, ordersDB[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		var uids []string
+		if err := tx.SelectContext(ctx, &uids, tx.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			inserted[uid] = true
+		}
+	}
+
+	// Only the first occurrence of an order_uid could have been inserted
+	newOrders := make([]*models.Order, 0, len(inserted))
+	for i, order := range orders {
+		if inserted[order.OrderUID] {
+			outcomes[i] = OrderInserted
+			newOrders = append(newOrders, order)
+			delete(inserted, order.OrderUID)
+		}
+	}
+
+	if err := insertOrderDetails(ctx, tx, newOrders); err != nil {
+		return nil, err
+	}
+	if err := insertInitialStatus(ctx, tx, newOrders); err != nil {
+		return nil, err
+	}
+	if err := insertCreatedEvents(ctx, tx, newOrders); err != nil {
+		return nil, err
+	}
+	if err := r.insertOrderAccepted(ctx, tx, newOrders, OrderInserted); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	return orderDB.ToModel()
+	return outcomes, nil
 }
 
-func (r *PostgresRepository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	var ordersDB []OrderDB
-	query := `SELECT * FROM orders`
+// insertOrderDetails writes deliveries, payments and items of the orders
+func insertOrderDetails(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	deliveries := make([]*DeliveryDB, 0, len(orders))
+	payments := make([]*PaymentDB, 0, len(orders))
+	items := make([]*ItemDB, 0, len(orders))
+	for _, order := range orders {
+		_, delivery, payment, orderItems := FromModel(order)
+		deliveries = append(deliveries, delivery)
+		payments = append(payments, payment)
+		items = append(items, orderItems...)
+	}
 
-	err := r.db.SelectContext(ctx, &ordersDB, query)
-	if err != nil {
+	if err := bulkExec(ctx, tx, insertDeliveryQuery, deliveries); err != nil {
+		return err
+	}
+	if err := bulkExec(ctx, tx, insertPaymentQuery, payments); err != nil {
+		return err
+	}
+	return bulkExec(ctx, tx, insertItemQuery, items)
+}
+
+// bulkExec runs a named insert for rows in chunks of bulkInsertChunk
+func bulkExec[T any](ctx context.Context, tx *sqlx.Tx, query string, rows []T) error {
+	for start := 0; start < len(rows); start += bulkInsertChunk {
+		end := min(start+bulkInsertChunk, len(rows))
+		if _, err := tx.NamedExecContext(ctx, query, rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOrders reads details of the order rows and assembles the orders,
+// keeping the order of rows
+func loadOrders(ctx context.Context, q sqlx.QueryerContext, ordersDB []OrderDB) ([]models.Order, error) {
+	if len(ordersDB) == 0 {
+		return []models.Order{}, nil
+	}
+
+	uids := make([]string, len(ordersDB))
+	for i, orderDB := range ordersDB {
+		uids[i] = orderDB.OrderUID
+	}
+
+	var deliveries []DeliveryDB
+	query := `SELECT order_uid, name, phone, zip, city, address, region, email
+		FROM deliveries WHERE order_uid = ANY($1)`
+	if err := sqlx.SelectContext(ctx, q, &deliveries, query, pq.Array(uids)); err != nil {
+		return nil, err
+	}
+
+	var payments []PaymentDB
+	query = `SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank,
+			delivery_cost, goods_total, custom_fee
+		FROM payments WHERE order_uid = ANY($1)`
+	if err := sqlx.SelectContext(ctx, q, &payments, query, pq.Array(uids)); err != nil {
 		return nil, err
 	}
 
+	var items []ItemDB
+	query = `SELECT order_uid, position, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items WHERE order_uid = ANY($1) ORDER BY order_uid, position`
+	if err := sqlx.SelectContext(ctx, q, &items, query, pq.Array(uids)); err != nil {
+		return nil, err
+	}
+
+	deliveryByUID := make(map[string]models.Delivery, len(deliveries))
+	for _, d := range deliveries {
+		deliveryByUID[d.OrderUID] = d.Delivery
+	}
+	paymentByUID := make(map[string]models.Payment, len(payments))
+	for _, p := range payments {
+		paymentByUID[p.OrderUID] = p.Payment
+	}
+	itemsByUID := make(map[string][]models.Item, len(ordersDB))
+	for _, item := range items {
+		itemsByUID[item.OrderUID] = append(itemsByUID[item.OrderUID], item.Item)
+	}
+
 	orders := make([]models.Order, len(ordersDB))
 	for i, orderDB := range ordersDB {
-		order, err := orderDB.ToModel()
-		if err != nil {
-			return nil, err
+		orderItems := itemsByUID[orderDB.OrderUID]
+		if orderItems == nil {
+			orderItems = []models.Item{}
 		}
-		orders[i] = *order
+		orders[i] = *orderDB.ToModel(deliveryByUID[orderDB.OrderUID], paymentByUID[orderDB.OrderUID], orderItems)
 	}
 
 	return orders, nil
 }
+
+func (r *PostgresRepository) GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error) {
+	var ordersDB []OrderDB
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE order_uid = $1`
+
+	err := r.db.SelectContext(ctx, &ordersDB, query, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ordersDB) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	orders, err := loadOrders(ctx, r.db, ordersDB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orders[0], nil
+}
+
+func (r *PostgresRepository) StreamOrders(ctx context.Context, opts StreamOptions, fn func([]*models.Order) error) error {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultStreamBatchSize
+	}
+
+	// A cursor lives in a transaction, which also gives the whole stream one
+	// consistent snapshot
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DECLARE stream_orders NO SCROLL CURSOR FOR
+		SELECT ` + orderColumns + ` FROM orders`
+	var args []interface{}
+	if !opts.Since.IsZero() {
+		args = append(args, opts.Since.UTC())
+		query += ` WHERE date_created >= $1`
+	}
+	query += ` ORDER BY date_created DESC, order_uid`
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM stream_orders`, batchSize)
+	for {
+		var ordersDB []OrderDB
+		if err := tx.SelectContext(ctx, &ordersDB, fetch); err != nil {
+			return err
+		}
+		if len(ordersDB) == 0 {
+			return nil
+		}
+
+		orders, err := loadOrders(ctx, tx, ordersDB)
+		if err != nil {
+			return err
+		}
+		batch := make([]*models.Order, len(orders))
+		for i := range orders {
+			batch[i] = &orders[i]
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *PostgresRepository) SaveQuarantinedMessage(ctx context.Context, msg *models.QuarantinedMessage) error {
+	headersJSON, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+
+	var validationJSON []byte
+	if len(msg.ValidationErrors) > 0 {
+		if validationJSON, err = json.Marshal(msg.ValidationErrors); err != nil {
+			return err
+		}
+	}
+
+	// The same message may be dead-lettered twice if the commit after quarantining fails
+	query := `INSERT INTO quarantined_messages (
+		topic, partition, "offset", message_key, payload, headers, error, validation_errors, attempts
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9
+	) ON CONFLICT (topic, partition, "offset") DO NOTHING`
+
+	_, err = r.db.ExecContext(ctx, query,
+		msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Payload, headersJSON, msg.Error, nullJSON(validationJSON), msg.Attempts)
+	return err
+}
+
+// nullJSON passes empty JSON as NULL, lib/pq would send a nil slice
+// as an empty string, which isn't valid JSONB
+func nullJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
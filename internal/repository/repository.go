@@ -3,11 +3,88 @@ package repository
 import (
 	"L0/internal/models"
 	"context"
+	"errors"
+	"time"
 )
 
+// ConflictPolicy decides what happens when an order arrives with an existing
+// order_uid but a different payload
+type ConflictPolicy string
+
+const (
+	ConflictReject        ConflictPolicy = "reject"
+	ConflictLastWriteWins ConflictPolicy = "last_write_wins"
+	ConflictNewerWins     ConflictPolicy = "newer_wins" // newer date_created wins
+)
+
+// SaveOutcome tells what SaveOrder did with the order
+type SaveOutcome int
+
+const (
+	OrderNotSaved SaveOutcome = iota // returned together with an error
+	OrderInserted
+	OrderUnchanged // identical re-delivery, nothing written
+	OrderUpdated   // existing order overwritten according to the policy
+	OrderStale     // existing order is newer and was kept
+	OrderConflict  // payload differs and the policy rejects changes
+)
+
+func (o SaveOutcome) String() string {
+	switch o {
+	case OrderNotSaved:
+		return "not_saved"
+	case OrderInserted:
+		return "inserted"
+	case OrderUnchanged:
+		return "unchanged"
+	case OrderUpdated:
+		return "updated"
+	case OrderStale:
+		return "stale"
+	case OrderConflict:
+		return "conflict"
+	}
+	return "unknown"
+}
+
+var ErrOrderConflict = errors.New("order already exists with a different payload")
+
+// StreamOptions selects the orders passed to StreamOrders, newest first
+type StreamOptions struct {
+	Limit     int       // 0 means all orders
+	Since     time.Time // zero means any date_created
+	BatchSize int
+}
+
 type OrderRepository interface {
-	SaveOrder(ctx context.Context, order *models.Order) error
+	SaveOrder(ctx context.Context, order *models.Order) (SaveOutcome, error)
+	SaveOrders(ctx context.Context, orders []*models.Order) ([]SaveOutcome, error)
 	RunMigrations(migrationsPath string) error
+	Ping(ctx context.Context) error
 	GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error)
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	// StreamOrders reads orders through a database cursor and passes them to fn
+	// in batches. An error returned by fn stops the stream.
+	StreamOrders(ctx context.Context, opts StreamOptions, fn func([]*models.Order) error) error
+	ListOrders(ctx context.Context, filter models.OrderFilter) (*models.OrderPage, error)
+	SaveQuarantinedMessage(ctx context.Context, msg *models.QuarantinedMessage) error
+	// ReserveIdempotencyKey claims the key for a new request and returns nil,
+	// or returns the record of an earlier request with the same key
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, response []byte) error
+	// ReleaseIdempotencyKey forgets a key whose request didn't complete
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	GetOrderStatus(ctx context.Context, orderUID string) (models.OrderStatus, error)
+	// ChangeOrderStatus applies the change only if the order is still in
+	// change.From, otherwise it returns ErrStatusChanged
+	ChangeOrderStatus(ctx context.Context, change *models.StatusChange) error
+	GetStatusHistory(ctx context.Context, orderUID string) ([]models.StatusChange, error)
+	// GetOrderEvents returns the audit trail of the order. SaveOrder,
+	// SaveOrders and ChangeOrderStatus record events in the same transaction
+	// as the changes.
+	GetOrderEvents(ctx context.Context, orderUID string) ([]models.OrderEvent, error)
+	// RelayOutbox passes up to limit unsent outbox messages to publish and
+	// marks them as sent if it succeeds. Saved and updated orders queue
+	// order_accepted messages in the same transaction as the order.
+	RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxMessage) error) (int, error)
+	DeleteSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error)
 }
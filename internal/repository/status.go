@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"L0/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrStatusChanged means the status of the order was changed concurrently,
+// so the transition has to be checked again
+var ErrStatusChanged = errors.New("order status changed concurrently")
+
+const insertStatusHistoryQuery = `INSERT INTO order_status_history (
+		order_uid, from_status, to_status, reason, occurred_at
+	) VALUES (
+		:order_uid, NULLIF(:from_status, ''), :to_status, :reason, :occurred_at
+	)`
+
+// insertInitialStatus starts the status history of new orders
+func insertInitialStatus(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	changes := make([]*models.StatusChange, len(orders))
+	for i, order := range orders {
+		changes[i] = &models.StatusChange{
+			OrderUID:   order.OrderUID,
+			To:         models.StatusCreated,
+			OccurredAt: order.DateCreated,
+		}
+	}
+	return bulkExec(ctx, tx, insertStatusHistoryQuery, changes)
+}
+
+// GetOrderStatus returns sql.ErrNoRows if the order doesn't exist
+func (r *PostgresRepository) GetOrderStatus(ctx context.Context, orderUID string) (models.OrderStatus, error) {
+	var status models.OrderStatus
+	err := r.db.GetContext(ctx, &status, `SELECT status FROM orders WHERE order_uid = $1`, orderUID)
+	return status, err
+}
+
+// ChangeOrderStatus moves the order from change.From to change.To and
+// records the change. It returns ErrStatusChanged if the order isn't in
+// change.From anymore.
+func (r *PostgresRepository) ChangeOrderStatus(ctx context.Context, change *models.StatusChange) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1 WHERE order_uid = $2 AND status = $3`,
+		change.To, change.OrderUID, change.From)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrStatusChanged
+	}
+
+	if _, err := tx.NamedExecContext(ctx, insertStatusHistoryQuery, change); err != nil {
+		return err
+	}
+	if err := insertStatusEvent(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStatusHistory returns the status changes of the order, oldest first
+func (r *PostgresRepository) GetStatusHistory(ctx context.Context, orderUID string) ([]models.StatusChange, error) {
+	history := []models.StatusChange{}
+	query := `SELECT order_uid, COALESCE(from_status, '') AS from_status, to_status, reason, occurred_at, recorded_at
+		FROM order_status_history WHERE order_uid = $1 ORDER BY id`
+	if err := r.db.SelectContext(ctx, &history, query, orderUID); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"L0/internal/health"
 	"L0/internal/logger"
+	"L0/internal/models"
+	"L0/internal/repository"
 	"L0/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +16,14 @@ import (
 
 type Handler struct {
 	orderService service.OrderService
+	health       *health.Checker
 	logger       logger.Logger
 }
 
-func NewHandler(orderService service.OrderService, logger logger.Logger) *Handler {
+func NewHandler(orderService service.OrderService, health *health.Checker, logger logger.Logger) *Handler {
 	return &Handler{
 		orderService: orderService,
+		health:       health,
 		logger:       logger.WithField("component", "http_handler"),
 	}
 }
@@ -65,3 +72,199 @@ func (h *Handler) GetOrder() gin.HandlerFunc {
 		c.JSON(http.StatusOK, response)
 	}
 }
+
+// GetOrderHistory returns the current status of the order and its status
+// changes, oldest first
+func (h *Handler) GetOrderHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderUID := c.Param("order_uid")
+		h.logger.Infof("HTTP request: GET /order/%s/history", orderUID)
+
+		history, err := h.orderService.GetOrderHistory(c.Request.Context(), orderUID)
+		if err != nil {
+			h.logger.Errorf("Failed to get history of order %s: %v", orderUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order history"})
+			return
+		}
+		if history == nil {
+			h.logger.Warnf("Order not found: %s", orderUID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// GetOrderEvents returns the audit trail of the order, oldest first, with
+// the fields changed by every event
+func (h *Handler) GetOrderEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderUID := c.Param("order_uid")
+		h.logger.Infof("HTTP request: GET /order/%s/events", orderUID)
+
+		events, err := h.orderService.GetOrderEvents(c.Request.Context(), orderUID)
+		if err != nil {
+			h.logger.Errorf("Failed to get events of order %s: %v", orderUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order events"})
+			return
+		}
+		// Every saved order has a created event, the trail of a deleted order
+		// is still returned
+		if len(events) == 0 {
+			h.logger.Warnf("No events of order: %s", orderUID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"order_uid": orderUID, "events": events})
+	}
+}
+
+// DiffOrderVersions compares the order data of two events of the audit trail
+// given by query parameters from and to
+func (h *Handler) DiffOrderVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderUID := c.Param("order_uid")
+		h.logger.Infof("HTTP request: GET /order/%s/diff", orderUID)
+
+		fromID, fromErr := strconv.ParseInt(c.Query("from"), 10, 64)
+		toID, toErr := strconv.ParseInt(c.Query("to"), 10, 64)
+		if fromErr != nil || toErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be event ids"})
+			return
+		}
+
+		changes, err := h.orderService.DiffOrderVersions(c.Request.Context(), orderUID, fromID, toID)
+		switch {
+		case errors.Is(err, service.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrNotOrderVersion):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			h.logger.Errorf("Failed to diff versions of order %s: %v", orderUID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare order versions"})
+			return
+		}
+
+		if changes == nil {
+			changes = []models.JSONChange{}
+		}
+		c.JSON(http.StatusOK, gin.H{"order_uid": orderUID, "from": fromID, "to": toID, "changes": changes})
+	}
+}
+
+// ListOrders searches orders by query parameters:
+// customer_id, track_number, delivery_service, locale, provider, bank, brand, nm_id,
+// date_from, date_to (RFC 3339), sort_by (date_created, order_uid), order (asc, desc),
+// limit and cursor (next_cursor of the previous page)
+func (h *Handler) ListOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.logger.Infof("HTTP request: GET /orders?%s", c.Request.URL.RawQuery)
+
+		filter, err := parseOrderFilter(c)
+		if err != nil {
+			h.logger.Warnf("Invalid list orders request: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := h.orderService.ListOrders(c.Request.Context(), filter)
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			h.logger.Warnf("Invalid cursor in request: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("Failed to list orders: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orders"})
+			return
+		}
+
+		h.logger.Infof("Orders listed successfully: %d", len(page.Orders))
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+func parseOrderFilter(c *gin.Context) (models.OrderFilter, error) {
+	filter := models.OrderFilter{
+		CustomerID:      c.Query("customer_id"),
+		TrackNumber:     c.Query("track_number"),
+		DeliveryService: c.Query("delivery_service"),
+		Locale:          c.Query("locale"),
+		Provider:        c.Query("provider"),
+		Bank:            c.Query("bank"),
+		Brand:           c.Query("brand"),
+		SortBy:          c.DefaultQuery("sort_by", models.SortByDateCreated),
+		Cursor:          c.Query("cursor"),
+	}
+
+	if filter.SortBy != models.SortByDateCreated && filter.SortBy != models.SortByOrderUID {
+		return filter, errors.New("sort_by must be date_created or order_uid")
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	if s := c.Query("nm_id"); s != "" {
+		nmID, err := strconv.Atoi(s)
+		if err != nil {
+			return filter, errors.New("nm_id must be an integer")
+		}
+		filter.NmID = nmID
+	}
+
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+
+	if s := c.Query("date_from"); s != "" {
+		t, err := models.ParseTimestamp(s)
+		if err != nil {
+			return filter, errors.New("date_from must be an RFC 3339 timestamp")
+		}
+		filter.CreatedFrom = &t.Time
+	}
+
+	if s := c.Query("date_to"); s != "" {
+		t, err := models.ParseTimestamp(s)
+		if err != nil {
+			return filter, errors.New("date_to must be an RFC 3339 timestamp")
+		}
+		filter.CreatedTo = &t.Time
+	}
+
+	return filter, nil
+}
+
+// Healthz reports that the process is alive, it doesn't touch dependencies
+func (h *Handler) Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+	}
+}
+
+// Readyz checks Postgres, Redis and Kafka and responds with 503 if any of them is down
+func (h *Handler) Readyz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.health.Check(c.Request.Context())
+		if report.Status != health.StatusUp {
+			h.logger.Warnf("Readiness check failed: %+v", report.Checks)
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
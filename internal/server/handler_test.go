@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseOrderFilterDates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	want := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "2021-11-26T06:22:19Z"},
+		{in: "2021-11-26t06:22:19z"},
+		{in: "2021-11-26 06:22:19Z"},
+		{in: "2021-11-26T09:22:19+03:00"},
+		{in: "2021-11-26", wantErr: true},
+		{in: "1637907739", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		for _, param := range []string{"date_from", "date_to"} {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/orders?"+param+"="+url.QueryEscape(tt.in), nil)
+
+			filter, err := parseOrderFilter(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("%s=%s accepted", param, tt.in)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s=%s: %v", param, tt.in, err)
+				continue
+			}
+			got := filter.CreatedFrom
+			if param == "date_to" {
+				got = filter.CreatedTo
+			}
+			if got == nil || !got.Equal(want) {
+				t.Errorf("%s=%s parsed as %v, want %v", param, tt.in, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"L0/internal/models"
+	"L0/internal/repository"
+	"L0/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxBodySize limits request bodies of the ingestion endpoints
+	maxBodySize = 32 << 20
+	// maxBatchOrders limits the number of orders in POST /orders/batch
+	maxBatchOrders = 1000
+)
+
+// batchResult is the result of a single order of POST /orders/batch
+type batchResult struct {
+	Index    int                 `json:"index"`
+	OrderUID string              `json:"order_uid,omitempty"`
+	Status   int                 `json:"status"`
+	Outcome  string              `json:"outcome,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Fields   []models.FieldError `json:"fields,omitempty"`
+}
+
+// CreateOrder accepts an order in the same format as the Kafka messages
+func (h *Handler) CreateOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.logger.Info("HTTP request: POST /orders")
+
+		body, err := readBody(c)
+		if err != nil {
+			h.respondBodyError(c, err)
+			return
+		}
+
+		var order models.Order
+		if err := json.Unmarshal(body, &order); err != nil {
+			h.logger.Warnf("Invalid order JSON: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+
+		outcome, err := h.orderService.CreateOrder(c.Request.Context(), &order)
+		if err != nil {
+			status, response := orderErrorResponse(err)
+			h.logger.Warnf("Failed to create order %s: %v", order.OrderUID, err)
+			c.JSON(status, response)
+			return
+		}
+
+		h.logger.Infof("Order %s accepted: %s", order.OrderUID, outcome)
+		c.JSON(saveStatus(outcome), gin.H{"order_uid": order.OrderUID, "outcome": outcome.String()})
+	}
+}
+
+// CreateOrders accepts a JSON array of orders or one order per line (NDJSON).
+// Every order succeeds or fails on its own; the response lists the result of
+// each one in the request order.
+func (h *Handler) CreateOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.logger.Info("HTTP request: POST /orders/batch")
+
+		body, err := readBody(c)
+		if err != nil {
+			h.respondBodyError(c, err)
+			return
+		}
+
+		raw, err := splitBatch(body, c.ContentType())
+		if err != nil {
+			h.logger.Warnf("Invalid batch: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(raw) > maxBatchOrders {
+			c.JSON(http.StatusRequestEntityTooLarge,
+				gin.H{"error": fmt.Sprintf("batch must contain at most %d orders", maxBatchOrders)})
+			return
+		}
+
+		results := make([]batchResult, len(raw))
+		orders := make([]*models.Order, 0, len(raw))
+		indexes := make([]int, 0, len(raw))
+		for i, r := range raw {
+			results[i].Index = i
+			var order models.Order
+			if err := json.Unmarshal(r, &order); err != nil {
+				results[i].Status = http.StatusBadRequest
+				results[i].Error = "invalid JSON: " + err.Error()
+				continue
+			}
+			results[i].OrderUID = order.OrderUID
+			orders = append(orders, &order)
+			indexes = append(indexes, i)
+		}
+
+		if len(orders) > 0 {
+			outcomes, errs := h.orderService.CreateOrders(c.Request.Context(), orders)
+			for j, i := range indexes {
+				if errs[j] != nil {
+					status, response := orderErrorResponse(errs[j])
+					results[i].Status = status
+					results[i].Error, _ = response["error"].(string)
+					results[i].Fields, _ = response["fields"].([]models.FieldError)
+					continue
+				}
+				results[i].Status = saveStatus(outcomes[j])
+				results[i].Outcome = outcomes[j].String()
+			}
+		}
+
+		// A retry may succeed only if something failed transiently, so only
+		// then the whole response is an error, which also frees the
+		// Idempotency-Key
+		status := http.StatusOK
+		accepted := 0
+		for _, r := range results {
+			if r.Status < http.StatusBadRequest {
+				accepted++
+			}
+			if r.Status == http.StatusServiceUnavailable {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		h.logger.Infof("Batch processed: %d of %d orders accepted", accepted, len(results))
+		c.JSON(status, gin.H{
+			"accepted": accepted,
+			"rejected": len(results) - accepted,
+			"results":  results,
+		})
+	}
+}
+
+func readBody(c *gin.Context) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+}
+
+func (h *Handler) respondBodyError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge,
+			gin.H{"error": fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit)})
+		return
+	}
+	h.logger.Warnf("Failed to read request body: %v", err)
+	c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+}
+
+// splitBatch splits the body into orders. NDJSON is expected for the
+// application/x-ndjson content type or when the body isn't a JSON array.
+func splitBatch(body []byte, contentType string) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if contentType != "application/x-ndjson" && bytes.HasPrefix(trimmed, []byte("[")) {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return raw, nil
+	}
+
+	var raw []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBodySize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		raw = append(raw, json.RawMessage(bytes.Clone(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid NDJSON: %w", err)
+	}
+	return raw, nil
+}
+
+// saveStatus maps the outcome of a successful save to an HTTP status
+func saveStatus(outcome repository.SaveOutcome) int {
+	if outcome == repository.OrderInserted {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// orderErrorResponse maps an error of OrderService.CreateOrder to an HTTP
+// status and body. Transient errors are worth retrying and get 503.
+func orderErrorResponse(err error) (int, gin.H) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity, gin.H{
+			"error":  "validation failed",
+			"fields": validationErr.Fields,
+		}
+	case errors.Is(err, repository.ErrOrderConflict):
+		return http.StatusConflict, gin.H{"error": repository.ErrOrderConflict.Error()}
+	case service.IsPermanent(err):
+		return http.StatusUnprocessableEntity, gin.H{"error": err.Error()}
+	default:
+		return http.StatusServiceUnavailable, gin.H{"error": "failed to save order, retry later"}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"L0/internal/logger"
+	"L0/internal/models"
+	"L0/internal/repository"
+	"L0/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSplitBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "json array",
+			body:        `[{"order_uid":"a"}, {"order_uid":"b"}]`,
+			contentType: "application/json",
+			want:        []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:        "json array with surrounding space",
+			body:        "\n  [{\"order_uid\":\"a\"}]\n",
+			contentType: "application/json",
+			want:        []string{`{"order_uid":"a"}`},
+		},
+		{
+			name:        "empty json array",
+			body:        `[]`,
+			contentType: "application/json",
+			want:        []string{},
+		},
+		{
+			name:        "invalid json array",
+			body:        `[{"order_uid":"a"},`,
+			contentType: "application/json",
+			wantErr:     true,
+		},
+		{
+			name:        "ndjson",
+			body:        "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}\n",
+			contentType: "application/x-ndjson",
+			want:        []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:        "ndjson without content type",
+			body:        "{\"order_uid\":\"a\"}\n{\"order_uid\":\"b\"}",
+			contentType: "",
+			want:        []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:        "ndjson skips blank lines",
+			body:        "\n{\"order_uid\":\"a\"}\n\n   \n\t{\"order_uid\":\"b\"}  \r\n\n",
+			contentType: "application/x-ndjson",
+			want:        []string{`{"order_uid":"a"}`, `{"order_uid":"b"}`},
+		},
+		{
+			name:        "ndjson line starting with an array",
+			body:        "[1]\n{\"order_uid\":\"a\"}",
+			contentType: "application/x-ndjson",
+			want:        []string{`[1]`, `{"order_uid":"a"}`},
+		},
+		{
+			name:        "ndjson keeps invalid lines for the per-order result",
+			body:        "{\"order_uid\":\"a\"}\nnot json",
+			contentType: "application/x-ndjson",
+			want:        []string{`{"order_uid":"a"}`, `not json`},
+		},
+		{
+			name:        "blank body",
+			body:        " \n\n ",
+			contentType: "application/x-ndjson",
+			want:        []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitBatch([]byte(tt.body), tt.contentType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("splitBatch() = %d orders, want an error", len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitBatch(): %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitBatch() = %d orders, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if string(got[i]) != tt.want[i] {
+					t.Errorf("order %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// acceptingService inserts every order of a batch
+type acceptingService struct {
+	service.OrderService
+}
+
+func (acceptingService) CreateOrders(ctx context.Context, orders []*models.Order) ([]repository.SaveOutcome, []error) {
+	outcomes := make([]repository.SaveOutcome, len(orders))
+	for i := range outcomes {
+		outcomes[i] = repository.OrderInserted
+	}
+	return outcomes, make([]error, len(orders))
+}
+
+func TestCreateOrdersBatchLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{orderService: acceptingService{}, logger: logger.NewLogger()}
+	r := gin.New()
+	r.POST("/orders/batch", h.CreateOrders())
+
+	tests := []struct {
+		orders int
+		want   int
+	}{
+		{1, http.StatusOK},
+		{maxBatchOrders, http.StatusOK},
+		{maxBatchOrders + 1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		body := strings.Repeat("{\"order_uid\":\"a\"}\n", tt.orders)
+		req := httptest.NewRequest(http.MethodPost, "/orders/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%d orders: status %d, want %d", tt.orders, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"L0/internal/logger"
+	"L0/internal/metrics"
+	"L0/internal/models"
+	"L0/internal/repository"
+	"L0/internal/service"
+	"L0/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("server")
+
+// metricsMiddleware counts requests and measures their latency per route
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Unknown paths share a single label to keep cardinality bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// tracingMiddleware continues the trace of the caller, if any, and starts a
+// server span for every request
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// actorHeader names who makes the request, for the audit trail
+const actorHeader = "X-Actor"
+
+// originMiddleware tells the repository where the changes made by the
+// request come from, so that they are recorded in the audit trail
+func originMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := c.GetHeader(actorHeader)
+		if actor == "" {
+			actor = "http"
+		}
+		origin := models.Origin{
+			Source: models.EventSource{HTTP: &models.HTTPSource{
+				Method:         c.Request.Method,
+				Path:           c.Request.URL.Path,
+				ClientIP:       c.ClientIP(),
+				UserAgent:      c.Request.UserAgent(),
+				IdempotencyKey: c.GetHeader(idempotencyKeyHeader),
+			}},
+			Actor: actor,
+		}
+		c.Request = c.Request.WithContext(models.WithOrigin(c.Request.Context(), origin))
+		c.Next()
+	}
+}
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayHeader marks a response replayed for a repeated key
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyMiddleware makes requests with an Idempotency-Key header safe to
+// retry: the first response to a key is stored and replayed for every repeat
+// of the same request. Failed requests (5xx) are not stored, so they can be
+// retried with the same key.
+func idempotencyMiddleware(orderService service.OrderService, logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		ctx := c.Request.Context()
+		record, err := orderService.BeginIdempotentRequest(ctx, key, requestHash)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "failed to check Idempotency-Key, retry later"})
+			return
+		case record != nil:
+			c.Header(idempotentReplayHeader, "true")
+			c.Data(record.StatusCode, gin.MIMEJSON+"; charset=utf-8", record.Response)
+			c.Abort()
+			return
+		}
+
+		// The request must not outlive its reservation of the key
+		reqCtx, cancel := context.WithTimeout(ctx, repository.IdempotentRequestTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(reqCtx)
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The key must be settled even if the client went away
+		ctx = context.WithoutCancel(ctx)
+		if status := recorder.Status(); status >= http.StatusInternalServerError {
+			if err := orderService.AbortIdempotentRequest(ctx, key); err != nil {
+				logger.Errorf("Failed to release idempotency key %s: %v", key, err)
+			}
+			return
+		}
+		if err := orderService.CompleteIdempotentRequest(ctx, key, recorder.Status(), recorder.body.Bytes()); err != nil {
+			logger.Errorf("Failed to store response for idempotency key %s: %v", key, err)
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response body
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"L0/internal/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,10 +13,24 @@ type Server struct {
 
 func NewServer(handler *Handler) *Server {
 	r := gin.Default()
+	r.Use(metricsMiddleware(), tracingMiddleware())
 
 	r.Static("/static", "./static") 
 
 	r.GET("/order/:order_uid", handler.GetOrder())
+	r.GET("/order/:order_uid/history", handler.GetOrderHistory())
+	r.GET("/order/:order_uid/events", handler.GetOrderEvents())
+	r.GET("/order/:order_uid/diff", handler.DiffOrderVersions())
+	r.GET("/orders", handler.ListOrders())
+
+	origin := originMiddleware()
+	idempotency := idempotencyMiddleware(handler.orderService, handler.logger)
+	r.POST("/orders", origin, idempotency, handler.CreateOrder())
+	r.POST("/orders/batch", origin, idempotency, handler.CreateOrders())
+
+	r.GET("/healthz", handler.Healthz())
+	r.GET("/readyz", handler.Readyz())
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	r.GET("/", func(c *gin.Context) {
 		c.File("./static/index.html")
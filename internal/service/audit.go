@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"L0/internal/models"
+)
+
+var (
+	ErrEventNotFound   = errors.New("event not found")
+	ErrNotOrderVersion = errors.New("event is not an order version")
+)
+
+func (s *OrderServiceImpl) GetOrderEvents(ctx context.Context, orderUID string) ([]models.OrderEvent, error) {
+	s.logger.Infof("Getting audit trail of order: %s", orderUID)
+
+	events, err := s.repo.GetOrderEvents(ctx, orderUID)
+	if err != nil {
+		s.logger.Errorf("Failed to get order events: %v", err)
+		return nil, err
+	}
+
+	// A created event has nothing to compare with, its data is the change
+	for i := range events {
+		if len(events[i].Previous) == 0 {
+			continue
+		}
+		events[i].Changes, err = models.DiffJSON(events[i].Previous, events[i].New)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff event %d: %w", events[i].ID, err)
+		}
+	}
+	return events, nil
+}
+
+// DiffOrderVersions compares the order data stored by two created or updated
+// events of the order, status changes don't hold the order
+func (s *OrderServiceImpl) DiffOrderVersions(ctx context.Context, orderUID string, fromID, toID int64) ([]models.JSONChange, error) {
+	s.logger.Infof("Comparing versions %d and %d of order %s", fromID, toID, orderUID)
+
+	events, err := s.repo.GetOrderEvents(ctx, orderUID)
+	if err != nil {
+		s.logger.Errorf("Failed to get order events: %v", err)
+		return nil, err
+	}
+
+	from, err := orderVersion(events, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := orderVersion(events, toID)
+	if err != nil {
+		return nil, err
+	}
+	return models.DiffJSON(from, to)
+}
+
+// orderVersion returns the order data stored by the event
+func orderVersion(events []models.OrderEvent, id int64) ([]byte, error) {
+	for _, event := range events {
+		if event.ID != id {
+			continue
+		}
+		if event.Type != models.EventCreated && event.Type != models.EventUpdated {
+			return nil, fmt.Errorf("%w: %d is %s", ErrNotOrderVersion, id, event.Type)
+		}
+		return event.New, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrEventNotFound, id)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+
+	"L0/internal/models"
+	"L0/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+// PermanentError wraps failures that will not go away on retry:
+// malformed payloads, validation failures, constraint violations
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// TransientError wraps failures caused by the environment, such as
+// DB/Redis connectivity or deadlines, which may succeed when retried
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err was classified as permanent
+func IsPermanent(err error) bool {
+	var permErr *PermanentError
+	return errors.As(err, &permErr)
+}
+
+// IsTransient reports whether err was classified as transient
+func IsTransient(err error) bool {
+	var transErr *TransientError
+	return errors.As(err, &transErr)
+}
+
+// Classify wraps err into PermanentError or TransientError.
+// Already classified errors are returned as is, unknown errors are treated as transient.
+func Classify(err error) error {
+	if err == nil || IsPermanent(err) || IsTransient(err) {
+		return err
+	}
+	if isPermanent(err) {
+		return &PermanentError{Err: err}
+	}
+	return &TransientError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var validationErr *models.ValidationError
+	var invalidValidationErr *validator.InvalidValidationError
+	var pqErr *pq.Error
+
+	switch {
+	case errors.Is(err, repository.ErrOrderConflict),
+		errors.As(err, &syntaxErr),
+		errors.As(err, &typeErr),
+		errors.As(err, &validationErr),
+		errors.As(err, &invalidValidationErr):
+		return true
+	case errors.As(err, &pqErr):
+		switch pqErr.Code.Class() {
+		case "22", // data_exception
+			"23", // integrity_constraint_violation, e.g. duplicate key
+			"42": // syntax_error_or_access_rule_violation
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"L0/internal/models"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+// BeginIdempotentRequest claims the key for a request identified by its hash.
+// It returns nil if the request should be processed, or the record of an
+// earlier completed request whose response should be replayed.
+func (s *OrderServiceImpl) BeginIdempotentRequest(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	record, err := s.repo.ReserveIdempotencyKey(ctx, key, requestHash)
+	if err != nil {
+		s.logger.Errorf("Failed to reserve idempotency key: %v", err)
+		return nil, err
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	switch {
+	case record.RequestHash != requestHash:
+		s.logger.Warnf("Idempotency key reused with a different request: %s", key)
+		return nil, ErrIdempotencyKeyReused
+	case record.StatusCode == 0:
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	s.logger.Infof("Replaying response for idempotency key: %s", key)
+	return record, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for the key
+func (s *OrderServiceImpl) CompleteIdempotentRequest(ctx context.Context, key string, statusCode int, response []byte) error {
+	if err := s.repo.CompleteIdempotencyKey(ctx, key, statusCode, response); err != nil {
+		s.logger.Errorf("Failed to save response for idempotency key: %v", err)
+		return err
+	}
+	return nil
+}
+
+// AbortIdempotentRequest releases the key, so the request can be retried
+func (s *OrderServiceImpl) AbortIdempotentRequest(ctx context.Context, key string) error {
+	if err := s.repo.ReleaseIdempotencyKey(ctx, key); err != nil {
+		s.logger.Errorf("Failed to release idempotency key: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"L0/internal/config"
+	"L0/internal/logger"
+	"L0/internal/metrics"
+	"L0/internal/models"
+	"L0/internal/repository"
+)
+
+// outboxCleanupInterval is how often sent outbox messages past the
+// retention are deleted
+const outboxCleanupInterval = 10 * time.Minute
+
+// EventPublisher delivers outbox messages downstream. It returns nil only if
+// every message was accepted.
+type EventPublisher interface {
+	Publish(ctx context.Context, msgs []models.OutboxMessage) error
+}
+
+// OutboxRelay publishes the messages queued in the outbox together with the
+// orders. Delivery is at least once: a message is marked as sent only after
+// it was published.
+type OutboxRelay struct {
+	repo      repository.OrderRepository
+	publisher EventPublisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	logger    logger.Logger
+}
+
+func NewOutboxRelay(cfg *config.Config, repo repository.OrderRepository, publisher EventPublisher, logger logger.Logger) *OutboxRelay {
+	interval := time.Duration(cfg.Outbox.PollInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	batchSize := cfg.Outbox.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+		retention: time.Duration(cfg.Outbox.Retention) * time.Second,
+		logger:    logger.WithField("component", "outbox_relay"),
+	}
+}
+
+// Run relays the outbox until the context is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		r.drain(ctx)
+
+		if time.Since(lastCleanup) >= outboxCleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain publishes batches while full ones keep coming
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.repo.RelayOutbox(ctx, r.batchSize, func(msgs []models.OutboxMessage) error {
+			return r.publisher.Publish(ctx, msgs)
+		})
+		if err != nil {
+			r.logger.Errorf("Failed to relay outbox: %v", err)
+			metrics.OutboxRelayFailures.Inc()
+			return
+		}
+		if n > 0 {
+			r.logger.Infof("Published %d outbox messages", n)
+			metrics.OutboxPublished.Add(float64(n))
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	deleted, err := r.repo.DeleteSentOutbox(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		r.logger.Errorf("Failed to clean up outbox: %v", err)
+		return
+	}
+	if deleted > 0 {
+		r.logger.Infof("Deleted %d sent outbox messages", deleted)
+	}
+}
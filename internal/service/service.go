@@ -2,82 +2,356 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"time"
 
 	"L0/internal/cache"
+	"L0/internal/config"
 	"L0/internal/logger"
+	"L0/internal/metrics"
 	"L0/internal/models"
 	"L0/internal/repository"
+	"L0/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 )
 
+var tracer = tracing.Tracer("service")
+
+// warmupReportInterval is how often the cache warm-up logs its progress
+const warmupReportInterval = 5 * time.Second
+
+// lookupTimeout bounds a database lookup shared by concurrent GetOrderByID
+// calls, which doesn't stop when the caller that started it gives up
+const lookupTimeout = 10 * time.Second
+
 type OrderService interface {
-	CreateOrder(ctx context.Context, order *models.Order) error
+	CreateOrder(ctx context.Context, order *models.Order) (repository.SaveOutcome, error)
+	CreateOrders(ctx context.Context, orders []*models.Order) ([]repository.SaveOutcome, []error)
 	GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter) (*models.OrderPage, error)
+	QuarantineMessage(ctx context.Context, msg *models.QuarantinedMessage) error
+	WarmUpCache(ctx context.Context) error
+	BeginIdempotentRequest(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error)
+	CompleteIdempotentRequest(ctx context.Context, key string, statusCode int, response []byte) error
+	AbortIdempotentRequest(ctx context.Context, key string) error
+	// ChangeOrderStatus moves the order along its lifecycle
+	ChangeOrderStatus(ctx context.Context, change *models.StatusChange) error
+	GetOrderHistory(ctx context.Context, orderUID string) (*models.OrderHistory, error)
+	// GetOrderEvents returns the audit trail of the order with the changes
+	// made by every event that has previous data
+	GetOrderEvents(ctx context.Context, orderUID string) ([]models.OrderEvent, error)
+	// DiffOrderVersions compares the order as it was after two events
+	DiffOrderVersions(ctx context.Context, orderUID string, fromID, toID int64) ([]models.JSONChange, error)
 }
 
 type OrderServiceImpl struct {
-	repo   repository.OrderRepository
-	cache  cache.Cache
-	logger logger.Logger
+	repo        repository.OrderRepository
+	cache       cache.Cache
+	rules       *models.RuleEngine
+	lookups     singleflight.Group
+	negativeTTL time.Duration
+	cacheConfig config.CacheConfig
+	logger      logger.Logger
 }
 
-func NewOrderService(repo repository.OrderRepository, cache cache.Cache, logger logger.Logger) OrderService {
-	orders, err := repo.GetAllOrders(context.Background())
-	if err == nil {
-		for _, order := range orders {
-			cache.Set(context.Background(), order.OrderUID, &order)
+func NewOrderService(cfg *config.Config, repo repository.OrderRepository, cache cache.Cache, logger logger.Logger) (OrderService, error) {
+	rules := models.NewRuleEngine(models.MoneyFromFloat(cfg.Orders.RuleTolerance), models.DefaultRules()...)
+	for name, severity := range cfg.Orders.RuleSeverity {
+		if err := rules.SetSeverity(name, models.Severity(severity)); err != nil {
+			return nil, err
 		}
 	}
 
 	return &OrderServiceImpl{
-		repo:   repo,
-		cache:  cache,
-		logger: logger.WithField("component", "order_service"),
-	}
+		repo:        repo,
+		cache:       cache,
+		rules:       rules,
+		negativeTTL: time.Duration(cfg.Cache.NegativeTTL) * time.Second,
+		cacheConfig: cfg.Cache,
+		logger:      logger.WithField("component", "order_service"),
+	}, nil
+}
+
+func (s *OrderServiceImpl) CreateOrder(ctx context.Context, order *models.Order) (repository.SaveOutcome, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrder")
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+
+	start := time.Now()
+	outcome, err := s.createOrder(ctx, order)
+	metrics.CreateOrderDuration.WithLabelValues(outcome.String()).Observe(time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.String("order.save_outcome", outcome.String()))
+	tracing.End(span, err)
+	return outcome, err
 }
 
-func (s *OrderServiceImpl) CreateOrder(ctx context.Context, order *models.Order) error {
+func (s *OrderServiceImpl) createOrder(ctx context.Context, order *models.Order) (repository.SaveOutcome, error) {
 	s.logger.Infof("Creating order: %s", order.OrderUID)
 
-	if err := models.ValidateOrder(order); err != nil {
+	if err := s.validate(order); err != nil {
 		s.logger.Errorf("Order validation failed: %v", err)
-		return err
+		return repository.OrderNotSaved, &PermanentError{Err: err}
 	}
 
-	if err := s.repo.SaveOrder(ctx, order); err != nil {
+	outcome, err := s.repo.SaveOrder(ctx, order)
+	if err != nil {
 		s.logger.Errorf("Failed to save order to database: %v", err)
-		return err
+		return outcome, Classify(err)
+	}
+
+	switch outcome {
+	case repository.OrderUnchanged:
+		s.logger.Infof("Order already saved, duplicate delivery ignored: %s", order.OrderUID)
+	case repository.OrderStale:
+		s.logger.Infof("Stored order is newer, update ignored: %s", order.OrderUID)
+		return outcome, nil
+	default:
+		s.logger.Infof("Order saved to database (%s): %s", outcome, order.OrderUID)
 	}
-	s.logger.Infof("Order saved to database: %s", order.OrderUID)
 
 	if err := s.cache.Set(ctx, order.OrderUID, order); err != nil {
 		s.logger.Warnf("Failed to cache order: %v", err)
 	}
 
+	return outcome, nil
+}
+
+// validate checks the order against its tags and the business rules. Rules
+// with the warn severity are only logged.
+func (s *OrderServiceImpl) validate(order *models.Order) error {
+	if err := models.ValidateOrder(order); err != nil {
+		return err
+	}
+
+	errs, warnings := s.rules.Check(order)
+	for _, fe := range warnings {
+		metrics.RuleViolations.WithLabelValues(fe.Rule, string(models.SeverityWarn)).Inc()
+		s.logger.Warnf("Order %s breaks rule %s: %s %s", order.OrderUID, fe.Rule, fe.Field, fe.Message)
+	}
+	for _, fe := range errs {
+		metrics.RuleViolations.WithLabelValues(fe.Rule, string(models.SeverityReject)).Inc()
+	}
+	if len(errs) > 0 {
+		return &models.ValidationError{Fields: errs}
+	}
 	return nil
 }
 
+// CreateOrders validates and saves a batch of orders using the bulk insert
+// path. Outcomes and errors are aligned with orders, so a failure of one
+// order doesn't affect the others.
+func (s *OrderServiceImpl) CreateOrders(ctx context.Context, orders []*models.Order) ([]repository.SaveOutcome, []error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrders")
+	span.SetAttributes(attribute.Int("orders.count", len(orders)))
+
+	start := time.Now()
+	outcomes, errs := s.createOrders(ctx, orders)
+	// Every order of the batch waited for the whole batch
+	elapsed := time.Since(start).Seconds()
+	for _, outcome := range outcomes {
+		metrics.CreateOrderDuration.WithLabelValues(outcome.String()).Observe(elapsed)
+	}
+
+	tracing.End(span, errors.Join(errs...))
+	return outcomes, errs
+}
+
+func (s *OrderServiceImpl) createOrders(ctx context.Context, orders []*models.Order) ([]repository.SaveOutcome, []error) {
+	s.logger.Infof("Creating batch of %d orders", len(orders))
+
+	outcomes := make([]repository.SaveOutcome, len(orders))
+	errs := make([]error, len(orders))
+
+	valid := make([]*models.Order, 0, len(orders))
+	validIdx := make([]int, 0, len(orders))
+	for i, order := range orders {
+		if err := s.validate(order); err != nil {
+			s.logger.Errorf("Order validation failed: %s: %v", order.OrderUID, err)
+			errs[i] = &PermanentError{Err: err}
+			continue
+		}
+		valid = append(valid, order)
+		validIdx = append(validIdx, i)
+	}
+
+	bulkOutcomes, err := s.repo.SaveOrders(ctx, valid)
+	if err != nil {
+		s.logger.Warnf("Bulk save failed, saving orders one by one: %v", err)
+		bulkOutcomes = make([]repository.SaveOutcome, len(valid))
+	}
+
+	toCache := make([]*models.Order, 0, len(valid))
+	for j, order := range valid {
+		i := validIdx[j]
+		outcomes[i] = bulkOutcomes[j]
+
+		// Existing orders and everything after a failed bulk insert go through
+		// the single-order path, which applies the conflict policy
+		if outcomes[i] == repository.OrderNotSaved {
+			outcomes[i], err = s.repo.SaveOrder(ctx, order)
+			if err != nil {
+				s.logger.Errorf("Failed to save order to database: %s: %v", order.OrderUID, err)
+				errs[i] = Classify(err)
+				continue
+			}
+		}
+
+		if outcomes[i] != repository.OrderStale {
+			toCache = append(toCache, order)
+		}
+	}
+	s.logger.Infof("Batch saved to database: %d of %d orders", len(toCache), len(orders))
+
+	if len(toCache) > 0 {
+		if err := s.cache.SetMany(ctx, toCache); err != nil {
+			s.logger.Warnf("Failed to cache orders: %v", err)
+		}
+	}
+
+	return outcomes, errs
+}
+
 func (s *OrderServiceImpl) GetOrderByID(ctx context.Context, orderUID string) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderByID")
+	span.SetAttributes(attribute.String("order.uid", orderUID))
+
+	order, err := s.getOrderByID(ctx, orderUID)
+	tracing.End(span, err)
+	return order, err
+}
+
+// getOrderByID returns nil without an error if the order doesn't exist
+func (s *OrderServiceImpl) getOrderByID(ctx context.Context, orderUID string) (*models.Order, error) {
 	s.logger.Infof("Getting order by ID: %s", orderUID)
 
-	if order, err := s.cache.Get(ctx, orderUID); err == nil && order != nil {
+	order, err := s.cache.Get(ctx, orderUID)
+	if errors.Is(err, cache.ErrNotFound) {
+		s.logger.Infof("Order cached as not found: %s", orderUID)
+		return nil, nil
+	}
+	if err == nil && order != nil {
 		s.logger.Infof("Order found in cache: %s", orderUID)
 		return order, nil
 	}
 
+	// Concurrent misses of the same order share a single database lookup
+	leader := false
+	result := s.lookups.DoChan(orderUID, func() (interface{}, error) {
+		leader = true
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lookupTimeout)
+		defer cancel()
+		return s.loadOrder(ctx, orderUID)
+	})
+
+	select {
+	case res := <-result:
+		if leader {
+			metrics.GetOrderLookups.WithLabelValues("database").Inc()
+		} else {
+			metrics.GetOrderLookups.WithLabelValues("coalesced").Inc()
+			s.logger.Infof("Order lookup coalesced with a concurrent one: %s", orderUID)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		order, _ := res.Val.(*models.Order)
+		return order, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// loadOrder reads the order from the database and caches the result, including
+// a short-lived mark for a missing order
+func (s *OrderServiceImpl) loadOrder(ctx context.Context, orderUID string) (*models.Order, error) {
 	order, err := s.repo.GetOrderByID(ctx, orderUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warnf("Order not found: %s", orderUID)
+		if s.negativeTTL > 0 {
+			if err := s.cache.SetNotFound(ctx, orderUID, s.negativeTTL); err != nil {
+				s.logger.Warnf("Failed to cache missing order: %v", err)
+			}
+		}
+		return nil, nil
+	}
 	if err != nil {
 		s.logger.Errorf("Failed to get order from database: %v", err)
 		return nil, err
 	}
 
-	if order != nil {
-		s.logger.Infof("Order found in database: %s", orderUID)
-		if err := s.cache.Set(ctx, orderUID, order); err != nil {
-			s.logger.Warnf("Failed to cache order: %v", err)
+	s.logger.Infof("Order found in database: %s", orderUID)
+	if err := s.cache.Set(ctx, orderUID, order); err != nil {
+		s.logger.Warnf("Failed to cache order: %v", err)
+	}
+	return order, nil
+}
+
+func (s *OrderServiceImpl) ListOrders(ctx context.Context, filter models.OrderFilter) (*models.OrderPage, error) {
+	s.logger.Infof("Listing orders: sort_by=%s, desc=%t, limit=%d", filter.SortBy, filter.Desc, filter.Limit)
+
+	page, err := s.repo.ListOrders(ctx, filter)
+	if err != nil {
+		s.logger.Errorf("Failed to list orders: %v", err)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// WarmUpCache streams the most recent orders from the database into the
+// cache. Orders that are already cached are left alone, so it is safe to run
+// while the service handles traffic. Cancel ctx to stop it.
+func (s *OrderServiceImpl) WarmUpCache(ctx context.Context) error {
+	opts := repository.StreamOptions{
+		Limit:     s.cacheConfig.WarmupLimit,
+		BatchSize: s.cacheConfig.WarmupBatchSize,
+	}
+	if s.cacheConfig.WarmupDays > 0 {
+		opts.Since = time.Now().AddDate(0, 0, -s.cacheConfig.WarmupDays)
+	}
+	s.logger.Infof("Cache warm-up started: limit=%d, days=%d", s.cacheConfig.WarmupLimit, s.cacheConfig.WarmupDays)
+
+	start := time.Now()
+	lastReport := start
+	warmed := 0
+	err := s.repo.StreamOrders(ctx, opts, func(orders []*models.Order) error {
+		if err := s.cache.AddMany(ctx, orders); err != nil {
+			return err
 		}
-	} else {
-		s.logger.Warnf("Order not found: %s", orderUID)
+		warmed += len(orders)
+		metrics.CacheWarmupOrders.Set(float64(warmed))
+
+		if time.Since(lastReport) >= warmupReportInterval {
+			lastReport = time.Now()
+			s.logger.Infof("Cache warm-up progress: %d orders, %.0f orders/s",
+				warmed, float64(warmed)/time.Since(start).Seconds())
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			s.logger.Infof("Cache warm-up cancelled after %d orders", warmed)
+			return ctx.Err()
+		}
+		s.logger.Errorf("Cache warm-up failed after %d orders: %v", warmed, err)
+		return err
 	}
 
-	return order, nil
+	s.logger.Infof("Cache warm-up completed: %d orders in %s", warmed, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func (s *OrderServiceImpl) QuarantineMessage(ctx context.Context, msg *models.QuarantinedMessage) error {
+	s.logger.Warnf("Quarantining message: topic=%s, partition=%d, offset=%d",
+		msg.Topic, msg.Partition, msg.Offset)
+
+	if err := s.repo.SaveQuarantinedMessage(ctx, msg); err != nil {
+		s.logger.Errorf("Failed to save quarantined message: %v", err)
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"L0/internal/metrics"
+	"L0/internal/models"
+	"L0/internal/repository"
+	"L0/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// statusChangeAttempts bounds how many times a status change is re-checked
+// after losing a race with a concurrent change of the same order
+const statusChangeAttempts = 3
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// transitions is the order lifecycle: the statuses an order may move to from
+// each status. Cancelled and returned orders are final.
+var transitions = map[models.OrderStatus][]models.OrderStatus{
+	models.StatusCreated:    {models.StatusPaid, models.StatusCancelled},
+	models.StatusPaid:       {models.StatusAssembling, models.StatusCancelled},
+	models.StatusAssembling: {models.StatusShipped, models.StatusCancelled},
+	models.StatusShipped:    {models.StatusDelivered, models.StatusReturned},
+	models.StatusDelivered:  {models.StatusReturned},
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to models.OrderStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OrderServiceImpl) ChangeOrderStatus(ctx context.Context, change *models.StatusChange) error {
+	ctx, span := tracer.Start(ctx, "OrderService.ChangeOrderStatus")
+	span.SetAttributes(
+		attribute.String("order.uid", change.OrderUID),
+		attribute.String("order.status", string(change.To)),
+	)
+
+	err := s.changeOrderStatus(ctx, change)
+	tracing.End(span, err)
+	return err
+}
+
+// changeOrderStatus applies the change if the lifecycle allows it. A repeated
+// event for the status the order is already in is acknowledged without
+// changes, so redelivered events are harmless.
+func (s *OrderServiceImpl) changeOrderStatus(ctx context.Context, change *models.StatusChange) error {
+	s.logger.Infof("Changing status of order %s to %s", change.OrderUID, change.To)
+
+	if err := models.ValidateStatusChange(change); err != nil {
+		s.logger.Errorf("Status change validation failed: %v", err)
+		// The status isn't known to be valid, so it can't be a label
+		metrics.StatusChanges.WithLabelValues("invalid", "rejected").Inc()
+		return &PermanentError{Err: err}
+	}
+	if change.OccurredAt.IsZero() {
+		change.OccurredAt = models.NewTimestamp(time.Now())
+	}
+
+	for attempt := 1; ; attempt++ {
+		from, err := s.repo.GetOrderStatus(ctx, change.OrderUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The order may still be on its way through the orders topic, so
+			// this is worth retrying
+			return &TransientError{Err: fmt.Errorf("%w: %s", ErrOrderNotFound, change.OrderUID)}
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to get order status: %v", err)
+			return Classify(err)
+		}
+
+		if from == change.To {
+			s.logger.Infof("Order %s is already %s, duplicate event ignored", change.OrderUID, change.To)
+			metrics.StatusChanges.WithLabelValues(string(change.To), "duplicate").Inc()
+			return nil
+		}
+		if !CanTransition(from, change.To) {
+			metrics.StatusChanges.WithLabelValues(string(change.To), "rejected").Inc()
+			return &PermanentError{Err: fmt.Errorf("%w of order %s: %s -> %s",
+				ErrInvalidTransition, change.OrderUID, from, change.To)}
+		}
+
+		change.From = from
+		err = s.repo.ChangeOrderStatus(ctx, change)
+		if errors.Is(err, repository.ErrStatusChanged) && attempt < statusChangeAttempts {
+			s.logger.Warnf("Status of order %s changed concurrently, checking again", change.OrderUID)
+			continue
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to change order status: %v", err)
+			return Classify(err)
+		}
+
+		s.logger.Infof("Order %s moved from %s to %s", change.OrderUID, from, change.To)
+		metrics.StatusChanges.WithLabelValues(string(change.To), "applied").Inc()
+		return nil
+	}
+}
+
+// GetOrderHistory returns nil without an error if the order doesn't exist
+func (s *OrderServiceImpl) GetOrderHistory(ctx context.Context, orderUID string) (*models.OrderHistory, error) {
+	s.logger.Infof("Getting status history of order: %s", orderUID)
+
+	status, err := s.repo.GetOrderStatus(ctx, orderUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to get order status: %v", err)
+		return nil, err
+	}
+
+	history, err := s.repo.GetStatusHistory(ctx, orderUID)
+	if err != nil {
+		s.logger.Errorf("Failed to get status history: %v", err)
+		return nil, err
+	}
+
+	return &models.OrderHistory{OrderUID: orderUID, Status: status, History: history}, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"L0/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []models.OrderStatus{
+		models.StatusCreated,
+		models.StatusPaid,
+		models.StatusAssembling,
+		models.StatusShipped,
+		models.StatusDelivered,
+		models.StatusCancelled,
+		models.StatusReturned,
+	}
+
+	// allowed lists every transition of the lifecycle, any other pair of
+	// statuses must be refused
+	allowed := map[[2]models.OrderStatus]bool{
+		{models.StatusCreated, models.StatusPaid}:         true,
+		{models.StatusCreated, models.StatusCancelled}:    true,
+		{models.StatusPaid, models.StatusAssembling}:      true,
+		{models.StatusPaid, models.StatusCancelled}:       true,
+		{models.StatusAssembling, models.StatusShipped}:   true,
+		{models.StatusAssembling, models.StatusCancelled}: true,
+		{models.StatusShipped, models.StatusDelivered}:    true,
+		{models.StatusShipped, models.StatusReturned}:     true,
+		{models.StatusDelivered, models.StatusReturned}:   true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]models.OrderStatus{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	for _, status := range statuses {
+		if CanTransition(status, "unknown") || CanTransition("unknown", status) {
+			t.Errorf("CanTransition allows a transition between %s and an unknown status", status)
+		}
+	}
+}
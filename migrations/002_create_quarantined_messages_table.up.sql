@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS quarantined_messages (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    partition INT NOT NULL,
+    "offset" BIGINT NOT NULL,
+    message_key BYTEA,
+    payload BYTEA,
+    headers JSONB,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (topic, partition, "offset")
+);
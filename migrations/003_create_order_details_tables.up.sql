@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS deliveries (
+    order_uid TEXT PRIMARY KEY REFERENCES orders (order_uid) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+    order_uid TEXT PRIMARY KEY REFERENCES orders (order_uid) ON DELETE CASCADE,
+    transaction TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount NUMERIC NOT NULL,
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost NUMERIC NOT NULL,
+    goods_total NUMERIC NOT NULL,
+    custom_fee NUMERIC NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS payments_transaction_idx ON payments (transaction);
+
+CREATE TABLE IF NOT EXISTS items (
+    order_uid TEXT NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    position INT NOT NULL,
+    chrt_id BIGINT NOT NULL,
+    track_number TEXT NOT NULL,
+    price NUMERIC NOT NULL,
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale NUMERIC NOT NULL,
+    size TEXT NOT NULL,
+    total_price NUMERIC NOT NULL,
+    nm_id BIGINT NOT NULL,
+    brand TEXT NOT NULL,
+    status INT NOT NULL,
+    PRIMARY KEY (order_uid, position)
+);
+
+CREATE INDEX IF NOT EXISTS items_nm_id_idx ON items (nm_id);
@@ -0,0 +1,59 @@
+BEGIN;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS delivery JSONB,
+    ADD COLUMN IF NOT EXISTS payment JSONB,
+    ADD COLUMN IF NOT EXISTS items JSONB;
+
+UPDATE orders o SET delivery = jsonb_build_object(
+    'name', d.name,
+    'phone', d.phone,
+    'zip', d.zip,
+    'city', d.city,
+    'address', d.address,
+    'region', d.region,
+    'email', d.email
+)
+FROM deliveries d
+WHERE d.order_uid = o.order_uid;
+
+UPDATE orders o SET payment = jsonb_build_object(
+    'transaction', p.transaction,
+    'request_id', p.request_id,
+    'currency', p.currency,
+    'provider', p.provider,
+    'amount', p.amount,
+    'payment_dt', p.payment_dt,
+    'bank', p.bank,
+    'delivery_cost', p.delivery_cost,
+    'goods_total', p.goods_total,
+    'custom_fee', p.custom_fee
+)
+FROM payments p
+WHERE p.order_uid = o.order_uid;
+
+UPDATE orders o SET items = i.items
+FROM (
+    SELECT order_uid, jsonb_agg(jsonb_build_object(
+        'chrt_id', chrt_id,
+        'track_number', track_number,
+        'price', price,
+        'rid', rid,
+        'name', name,
+        'sale', sale,
+        'size', size,
+        'total_price', total_price,
+        'nm_id', nm_id,
+        'brand', brand,
+        'status', status
+    ) ORDER BY position) AS items
+    FROM items
+    GROUP BY order_uid
+) i
+WHERE i.order_uid = o.order_uid;
+
+DELETE FROM items;
+DELETE FROM payments;
+DELETE FROM deliveries;
+
+COMMIT;
@@ -0,0 +1,63 @@
+BEGIN;
+
+INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
+SELECT
+    order_uid,
+    COALESCE(delivery->>'name', ''),
+    COALESCE(delivery->>'phone', ''),
+    COALESCE(delivery->>'zip', ''),
+    COALESCE(delivery->>'city', ''),
+    COALESCE(delivery->>'address', ''),
+    COALESCE(delivery->>'region', ''),
+    COALESCE(delivery->>'email', '')
+FROM orders
+WHERE jsonb_typeof(delivery) = 'object'
+ON CONFLICT (order_uid) DO NOTHING;
+
+INSERT INTO payments (
+    order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+)
+SELECT
+    order_uid,
+    COALESCE(payment->>'transaction', ''),
+    COALESCE(payment->>'request_id', ''),
+    COALESCE(payment->>'currency', ''),
+    COALESCE(payment->>'provider', ''),
+    COALESCE((payment->>'amount')::NUMERIC, 0),
+    COALESCE((payment->>'payment_dt')::BIGINT, 0),
+    COALESCE(payment->>'bank', ''),
+    COALESCE((payment->>'delivery_cost')::NUMERIC, 0),
+    COALESCE((payment->>'goods_total')::NUMERIC, 0),
+    COALESCE((payment->>'custom_fee')::NUMERIC, 0)
+FROM orders
+WHERE jsonb_typeof(payment) = 'object'
+ON CONFLICT (order_uid) DO NOTHING;
+
+INSERT INTO items (
+    order_uid, position, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+)
+SELECT
+    o.order_uid,
+    i.ordinality - 1,
+    COALESCE((i.item->>'chrt_id')::BIGINT, 0),
+    COALESCE(i.item->>'track_number', ''),
+    COALESCE((i.item->>'price')::NUMERIC, 0),
+    COALESCE(i.item->>'rid', ''),
+    COALESCE(i.item->>'name', ''),
+    COALESCE((i.item->>'sale')::NUMERIC, 0),
+    COALESCE(i.item->>'size', ''),
+    COALESCE((i.item->>'total_price')::NUMERIC, 0),
+    COALESCE((i.item->>'nm_id')::BIGINT, 0),
+    COALESCE(i.item->>'brand', ''),
+    COALESCE((i.item->>'status')::INT, 0)
+FROM orders o
+CROSS JOIN LATERAL jsonb_array_elements(o.items) WITH ORDINALITY AS i (item, ordinality)
+WHERE jsonb_typeof(o.items) = 'array'
+ON CONFLICT (order_uid, position) DO NOTHING;
+
+ALTER TABLE orders
+    DROP COLUMN delivery,
+    DROP COLUMN payment,
+    DROP COLUMN items;
+
+COMMIT;
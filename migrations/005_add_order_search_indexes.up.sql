@@ -0,0 +1,8 @@
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created, order_uid);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+CREATE INDEX IF NOT EXISTS orders_delivery_service_idx ON orders (delivery_service);
+CREATE INDEX IF NOT EXISTS orders_locale_idx ON orders (locale);
+CREATE INDEX IF NOT EXISTS payments_provider_idx ON payments (provider);
+CREATE INDEX IF NOT EXISTS payments_bank_idx ON payments (bank);
+CREATE INDEX IF NOT EXISTS items_brand_idx ON items (brand);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status_code INT,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
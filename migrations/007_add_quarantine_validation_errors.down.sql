@@ -0,0 +1 @@
+ALTER TABLE quarantined_messages DROP COLUMN IF EXISTS validation_errors;
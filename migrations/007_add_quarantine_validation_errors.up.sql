@@ -0,0 +1 @@
+ALTER TABLE quarantined_messages ADD COLUMN IF NOT EXISTS validation_errors JSONB;
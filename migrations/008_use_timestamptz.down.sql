@@ -0,0 +1,5 @@
+ALTER TABLE payments
+    ALTER COLUMN payment_dt TYPE BIGINT USING EXTRACT(EPOCH FROM payment_dt)::BIGINT;
+
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMP USING date_created AT TIME ZONE 'UTC';
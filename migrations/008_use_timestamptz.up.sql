@@ -0,0 +1,6 @@
+-- date_created was stored in UTC without the time zone
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created AT TIME ZONE 'UTC';
+
+ALTER TABLE payments
+    ALTER COLUMN payment_dt TYPE TIMESTAMPTZ USING to_timestamp(payment_dt);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS order_events_append_only ON order_events;
+DROP FUNCTION IF EXISTS order_events_append_only();
+DROP TABLE IF EXISTS order_events;
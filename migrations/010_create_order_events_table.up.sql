@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    -- No foreign key, the trail outlives the order
+    order_uid TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    previous_data JSONB,
+    new_data JSONB,
+    source JSONB NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_events_order_uid_idx ON order_events (order_uid, id);
+
+-- The audit trail is append-only
+CREATE OR REPLACE FUNCTION order_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_events_append_only
+    BEFORE UPDATE OR DELETE ON order_events
+    FOR EACH ROW EXECUTE FUNCTION order_events_append_only();
@@ -0,0 +1,9 @@
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC,
+    ALTER COLUMN total_price TYPE NUMERIC;
+
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE NUMERIC,
+    ALTER COLUMN delivery_cost TYPE NUMERIC,
+    ALTER COLUMN goods_total TYPE NUMERIC,
+    ALTER COLUMN custom_fee TYPE NUMERIC;
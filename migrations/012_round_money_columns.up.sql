@@ -0,0 +1,11 @@
+-- Money keeps four decimal places. Amounts stored with more, before they were
+-- validated, are rounded so that they can be read again.
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE NUMERIC(20, 4) USING round(amount, 4),
+    ALTER COLUMN delivery_cost TYPE NUMERIC(20, 4) USING round(delivery_cost, 4),
+    ALTER COLUMN goods_total TYPE NUMERIC(20, 4) USING round(goods_total, 4),
+    ALTER COLUMN custom_fee TYPE NUMERIC(20, 4) USING round(custom_fee, 4);
+
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC(20, 4) USING round(price, 4),
+    ALTER COLUMN total_price TYPE NUMERIC(20, 4) USING round(total_price, 4);